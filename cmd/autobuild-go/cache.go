@@ -0,0 +1,141 @@
+package main
+
+import (
+	"autobuild-go/internal/colors"
+	"autobuild-go/internal/downloader"
+	"autobuild-go/internal/models"
+	"autobuild-go/internal/remotecache"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const cacheServerUsage = `Usage: autobuild-go cache-server [flags]
+
+Serves shared build cache stored in a directory. Files are read with GET and stored with PUT of /<key>/<name>.
+Point cache.remote in autobuild.yaml at the server to share artifacts and (with cache.go_cache) Go build cache between agents.
+
+Flags:
+`
+
+const cacheProgUsage = `Usage: autobuild-go cache-prog [flags]
+
+GOCACHEPROG program keeping Go build cache in a local directory and sharing it through remote cache server.
+Credentials are taken from AUTOBUILD_CACHE_TOKEN or AUTOBUILD_CACHE_USERNAME and AUTOBUILD_CACHE_PASSWORD environment variables,
+download settings from AUTOBUILD_DOWNLOAD_PROXY, AUTOBUILD_DOWNLOAD_CA_FILE, AUTOBUILD_DOWNLOAD_TIMEOUT and AUTOBUILD_DOWNLOAD_RETRIES.
+
+Flags:
+`
+
+// runCacheServerCommand handles `autobuild-go cache-server` subcommand
+func runCacheServerCommand(args []string) int {
+	fs := flag.NewFlagSet("cache-server", flag.ExitOnError)
+	dir := fs.String("dir", "cache", "Directory storing cache entries")
+	addr := fs.String("addr", ":8080", "Listen address")
+	token := fs.String("token", os.Getenv("AUTOBUILD_CACHE_TOKEN"), "Token required from clients as bearer token or basic auth password, defaults to AUTOBUILD_CACHE_TOKEN")
+	allowAnonymous := fs.Bool("allow-anonymous", false, "Serve without token, anyone reaching the server can read and write the cache")
+	maxSize := fs.Int64("max-size", 1024, "Maximum size of a stored object in MiB")
+	timeout := fs.Duration("timeout", 10*time.Minute, "Maximum duration of reading a request and writing a response")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), cacheServerUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	path, err := filepath.Abs(*dir)
+	if err != nil {
+		colors.ErrLog("Invalid cache directory `%s`: %v", *dir, err)
+		return 1
+	}
+	if err := os.MkdirAll(path, os.ModePerm); err != nil {
+		colors.ErrLog("Cannot create cache directory `%s`: %v", path, err)
+		return 1
+	}
+	if *token == "" && !*allowAnonymous {
+		colors.ErrLog("No token configured, set AUTOBUILD_CACHE_TOKEN or --token (or --allow-anonymous to serve anyone)")
+		return 1
+	} else if *token == "" {
+		colors.WarnLog("No token configured, anyone reaching the server can read and write the cache")
+	}
+	if *maxSize <= 0 {
+		colors.ErrLog("Invalid maximum object size %d MiB", *maxSize)
+		return 1
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           remotecache.NewServer(path, *token, *maxSize<<20),
+		ReadHeaderTimeout: 30 * time.Second,
+		ReadTimeout:       *timeout,
+		WriteTimeout:      *timeout,
+		IdleTimeout:       2 * time.Minute,
+	}
+	colors.InfoLog("Serving build cache from "+colors.Blue+"%s"+colors.Reset+" on %s", path, *addr)
+	if err := server.ListenAndServe(); err != nil {
+		colors.ErrLog("Cache server failed: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// runCacheProgCommand handles `autobuild-go cache-prog` subcommand started by the go command through GOCACHEPROG
+func runCacheProgCommand(args []string) int {
+	fs := flag.NewFlagSet("cache-prog", flag.ExitOnError)
+	dir := fs.String("dir", "", "Directory storing Go build cache objects (required)")
+	remote := fs.String("remote", "", "URL of cache server")
+	readOnly := fs.Bool("read-only", false, "Only download from cache server, never upload")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), cacheProgUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *dir == "" {
+		fs.Usage()
+		return 1
+	}
+
+	// Stdout carries the protocol, logs go to stderr
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+
+	download := models.Download{
+		Proxy:   os.Getenv("AUTOBUILD_DOWNLOAD_PROXY"),
+		CAFile:  os.Getenv("AUTOBUILD_DOWNLOAD_CA_FILE"),
+		Timeout: os.Getenv("AUTOBUILD_DOWNLOAD_TIMEOUT"),
+	}
+	if value := os.Getenv("AUTOBUILD_DOWNLOAD_RETRIES"); value != "" {
+		retries, err := strconv.Atoi(value)
+		if err != nil {
+			colors.ErrLog("Invalid AUTOBUILD_DOWNLOAD_RETRIES `%s`: %v", value, err)
+			return 1
+		}
+		download.Retries = &retries
+	}
+	dl, err := downloader.New(download)
+	if err != nil {
+		colors.ErrLog("Cannot create HTTP client: %v", err)
+		return 1
+	}
+	client := remotecache.New(models.Cache{
+		Remote:   *remote,
+		Token:    os.Getenv("AUTOBUILD_CACHE_TOKEN"),
+		Username: os.Getenv("AUTOBUILD_CACHE_USERNAME"),
+		Password: os.Getenv("AUTOBUILD_CACHE_PASSWORD"),
+		ReadOnly: *readOnly,
+	}, dl.Client())
+	if err := remotecache.NewProg(*dir, client).Run(os.Stdin, stdout); err != nil {
+		colors.ErrLog("GOCACHEPROG failed: %v", err)
+		return 1
+	}
+	return 0
+}
+
+func init() {
+	subcommands["cache-server"] = runCacheServerCommand
+	subcommands["cache-prog"] = runCacheProgCommand
+}
@@ -4,10 +4,13 @@ import (
 	"autobuild-go/internal/builder"
 	"autobuild-go/internal/colors"
 	"autobuild-go/internal/config"
+	"autobuild-go/internal/downloader"
+	"autobuild-go/internal/gitversion"
 	"autobuild-go/internal/golanginstaller"
 	"autobuild-go/internal/gopkginstaller"
 	"autobuild-go/internal/models"
 	"autobuild-go/internal/processors"
+	"autobuild-go/internal/utils"
 	"fmt"
 	_ "gopkg.in/yaml.v2"
 	"os"
@@ -19,6 +22,9 @@ import (
 
 const autobuildGoHeader = "    _       _       ___      _ _    _      ___     \n   /_\\ _  _| |_ ___| _ )_  _(_) |__| |___ / __|___ \n  / _ \\ || |  _/ _ \\ _ \\ || | | / _` |___| (_ / _ \\\n /_/ \\_\\_,_|\\__\\___/___/\\_,_|_|_\\__,_|    \\___\\___/\n                                                   "
 
+// subcommands maps subcommand name (first argument) to its handler returning exit code
+var subcommands = map[string]func(args []string) int{}
+
 // Check if git is installed
 func isGitInstalled() bool {
 	_, err := exec.LookPath("git")
@@ -26,19 +32,54 @@ func isGitInstalled() bool {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if handler, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(handler(os.Args[2:]))
+		}
+	}
 
 	fmt.Printf(colors.Purple+autobuildGoHeader+colors.Reset+"\n\t%d (c) Mateusz Mierzwinski - matt@mattmierzwinski.com\n\tThis is a free software released under BSD-2 simplified license.\n\tSource: https://github.com/mateuszmierzwinski/autobuild-go\n\n", time.Now().Year())
 
+	args := config.ParseArgs()
+	path := args.Path
+
+	colors.HorizontalLine("Configuration")
+	conf := config.GetProfileConfig(args)
+	if conf.Offline {
+		colors.InfoLog("Offline mode enabled, only installed toolchains and cached packages are used")
+	}
+	if conf.CurrentVersion == "" && conf.Profile.Version == "git" {
+		version, err := gitversion.Derive(path)
+		if err != nil {
+			colors.ErrLog("Cannot derive version from git: %v", err)
+			os.Exit(1)
+		}
+		conf.CurrentVersion = version
+		colors.Success("Version derived from git: %s%s%s", colors.Blue, version, colors.Reset)
+	} else if conf.CurrentVersion == "" {
+		conf.CurrentVersion = conf.Profile.Version
+	}
+
+	dl, err := downloader.New(conf.Download)
+	if err != nil {
+		colors.ErrLog("Invalid download configuration: %v", err)
+		os.Exit(1)
+	}
+
 	colors.HorizontalLine("Autoupdate")
 	colors.InfoLog("Current app version is: %s%s%s", colors.Blue, releaseVersion, colors.Reset)
-	updateInfo, err := CheckUpdates()
-	if err != nil {
-		colors.ErrLog("cannot check latest version: %v", err)
+	if conf.Offline && (conf.Mirrors.Releases == "" || !utils.IsLocalURL(conf.Mirrors.Releases)) {
+		colors.InfoLog("Update check skipped in offline mode")
 	} else {
-		if strings.Contains(updateInfo, "using the latest") {
-			colors.Success(updateInfo)
+		updateInfo, err := CheckUpdates(dl, conf.Mirrors.Releases)
+		if err != nil {
+			colors.ErrLog("cannot check latest version: %v", err)
 		} else {
-			colors.InfoLog(updateInfo)
+			if strings.Contains(updateInfo, "using the latest") {
+				colors.Success(updateInfo)
+			} else {
+				colors.InfoLog(updateInfo)
+			}
 		}
 	}
 
@@ -49,34 +90,8 @@ func main() {
 	}
 	colors.Success("Git is installed.")
 
-	var path string
-
-	deflen := 1
-	allFlag := strings.Join(os.Args, " ")
-	switch {
-	case strings.Contains(allFlag, "--profile"):
-		deflen += 2
-	case strings.Contains(allFlag, "--release"):
-		deflen += 2
-	case strings.Contains(allFlag, "--help"):
-		deflen += 1
-	}
-
-	if len(os.Args) > deflen {
-		path = os.Args[len(os.Args)-1]
-	} else {
-		var err error
-		path, err = os.Getwd()
-		if err != nil {
-			colors.ErrLog("Error trying to get current dir: %v", err)
-			os.Exit(1)
-		}
-	}
-
-	conf := config.GetProfileConfig(path)
-
 	// Create a new GoInstaller instance
-	installer := golanginstaller.New(path, conf)
+	installer := golanginstaller.New(path, conf, dl)
 
 	// Ensure Go is installed
 	if err := installer.EnsureGo(); err != nil {
@@ -85,17 +100,22 @@ func main() {
 	}
 
 	colors.HorizontalLine("Extra tools and packages")
-	gopkgInstaller := gopkginstaller.New(installer.GoToolchainDir(), map[string]string{
-		"gosec": "github.com/securego/gosec/v2/cmd/gosec@latest",
-	})
-	gopkgInstaller.Install()
+	gopkgInstaller := gopkginstaller.New(installer.Primary(), gopkginstaller.ConfiguredTools(conf), conf.GoNetworkEnv())
+	if err := gopkgInstaller.Install(); err != nil {
+		colors.ErrLog("Error installing tools: %v", err)
+		os.Exit(1)
+	}
 
 	colors.HorizontalLine("Testing & building Go projects")
 
 	projectDestChan := make(chan models.Project, 5)
 
 	proc := processors.NewProjectWalkerProcessor(path, filepath.Join(path, ".build"), projectDestChan)
-	gobuilder := builder.NewGoBuilder(installer.GoToolchainDir(), conf)
+	gobuilder, err := builder.NewGoBuilder(installer.Primary(), installer.Toolchains(), conf)
+	if err != nil {
+		colors.ErrLog("Invalid build configuration: %v", err)
+		os.Exit(1)
+	}
 
 	// Run the processor in a separate goroutine
 	go func() {
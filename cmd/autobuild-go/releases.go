@@ -1,10 +1,9 @@
 package main
 
 import (
+	"autobuild-go/internal/downloader"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 )
 
 const repoURL = "https://api.github.com/repos/mateuszmierzwinski/autobuild-go/releases"
@@ -16,26 +15,14 @@ type Repo struct {
 
 var releaseVersion string
 
-// getVersions retrieves the list of releases from the GitHub API
-func getVersions() ([]Repo, error) {
+// getVersions retrieves the list of releases from the release feed (GitHub API by default)
+func getVersions(dl *downloader.Downloader, feedURL string) ([]Repo, error) {
 	var repos []Repo
 
-	// Fetch data from GitHub API
-	result, err := http.Get(repoURL)
+	// Fetch data from release feed
+	body, err := dl.Fetch(feedURL)
 	if err != nil {
-		return repos, err
-	}
-	defer result.Body.Close()
-
-	// Check for a successful response
-	if result.StatusCode != http.StatusOK {
-		return repos, fmt.Errorf("GitHub API error: Status %d", result.StatusCode)
-	}
-
-	// Read and unmarshal the response body
-	body, err := io.ReadAll(result.Body)
-	if err != nil {
-		return repos, fmt.Errorf("GitHub API error: Invalid response: %v", err)
+		return repos, fmt.Errorf("GitHub API error: %v", err)
 	}
 
 	if err = json.Unmarshal(body, &repos); err != nil {
@@ -45,9 +32,14 @@ func getVersions() ([]Repo, error) {
 	return repos, nil
 }
 
-// CheckUpdates compares the latest release from GitHub with the current release version
-func CheckUpdates() (string, error) {
-	allVersions, err := getVersions()
+// CheckUpdates compares the latest release from the release feed with the current release version.
+// Empty feedURL means GitHub releases
+func CheckUpdates(dl *downloader.Downloader, feedURL string) (string, error) {
+	if feedURL == "" {
+		feedURL = repoURL
+	}
+
+	allVersions, err := getVersions(dl, feedURL)
 	if err != nil {
 		return "", fmt.Errorf("cannot get latest version: %v", err)
 	}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"autobuild-go/internal/colors"
+	"autobuild-go/internal/config"
+	"autobuild-go/internal/downloader"
+	"autobuild-go/internal/golanginstaller"
+	"autobuild-go/internal/utils"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+const toolchainUsage = `Usage: autobuild-go toolchain <command> [flags]
+
+Commands:
+  list                 List installed toolchains with size and last use time
+  install <version>    Install toolchain (e.g. 1.22.5, 1.21.x, latest)
+  use <version>        Install toolchain and print shell exports for it, e.g. eval "$(autobuild-go toolchain use 1.22.x)"
+  prune --keep N       Remove all but N most recently used toolchains with their GOPATH and tool caches
+
+Flags:
+`
+
+// runToolchainCommand handles `autobuild-go toolchain ...` subcommand
+func runToolchainCommand(args []string) int {
+	fs := flag.NewFlagSet("toolchain", flag.ExitOnError)
+	projectPath := fs.String("path", ".", "Project directory containing autobuild.yaml with toolchain location")
+	offline := fs.Bool("offline", false, "Never access the network")
+	keep := fs.Int("keep", 2, "Number of toolchains kept by prune")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), toolchainUsage)
+		fs.PrintDefaults()
+	}
+
+	if len(args) == 0 {
+		fs.Usage()
+		return 1
+	}
+	command := args[0]
+	fs.Parse(args[1:])
+
+	path, err := filepath.Abs(*projectPath)
+	if err != nil {
+		colors.ErrLog("Invalid path `%s`: %v", *projectPath, err)
+		return 1
+	}
+	conf := config.GetBaseConfig(path, *offline)
+	dl, err := downloader.New(conf.Download)
+	if err != nil {
+		colors.ErrLog("Invalid download configuration: %v", err)
+		return 1
+	}
+	installer := golanginstaller.New(path, conf, dl)
+
+	switch command {
+	case "list":
+		installed, err := installer.ListInstalled()
+		if err != nil {
+			colors.ErrLog("Cannot list toolchains: %v", err)
+			return 1
+		}
+		if len(installed) == 0 {
+			colors.InfoLog("No toolchains installed")
+			return 0
+		}
+		fmt.Printf("    %-12s %10s  %-20s %s\n", "VERSION", "SIZE", "LAST USED", "LOCATION")
+		for _, toolchain := range installed {
+			fmt.Printf("    %-12s %10s  %-20s %s\n", toolchain.Version, utils.HumanSize(toolchain.Size), toolchain.LastUsed.Local().Format(time.DateTime), toolchain.Dir)
+		}
+	case "install", "use":
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return 1
+		}
+		stdout := os.Stdout
+		if command == "use" {
+			// Installer logs go to stderr, so stdout holds only the exports
+			os.Stdout = os.Stderr
+		}
+		install := installer.Install
+		if command == "use" {
+			install = installer.Use
+		}
+		toolchain, err := install(fs.Arg(0))
+		os.Stdout = stdout
+		if err != nil {
+			colors.ErrLog("Cannot install Go %s: %v", fs.Arg(0), err)
+			return 1
+		}
+		if command == "use" {
+			// Exports go to stdout, so they can be evaluated by the shell
+			if runtime.GOOS == "windows" {
+				fmt.Printf("$env:GOROOT=\"%s\"\n$env:GOPATH=\"%s\"\n$env:PATH=\"%s;$env:PATH\"\n", toolchain.GoRoot(), toolchain.GoPath(), filepath.Join(toolchain.GoRoot(), "bin"))
+			} else {
+				fmt.Printf("export GOROOT=%q\nexport GOPATH=%q\nexport PATH=%q:\"$PATH\"\n", toolchain.GoRoot(), toolchain.GoPath(), filepath.Join(toolchain.GoRoot(), "bin"))
+			}
+		}
+	case "prune":
+		removed, err := installer.Prune(*keep)
+		if err != nil {
+			colors.ErrLog("Cannot prune toolchains: %v", err)
+			return 1
+		}
+		var freed int64
+		for _, toolchain := range removed {
+			freed += toolchain.Size
+			colors.Success("Removed Go %s%s%s from %s", colors.Blue, toolchain.Version, colors.Reset, toolchain.Dir)
+		}
+		colors.InfoLog("Removed %d toolchain(s), freed %s", len(removed), utils.HumanSize(freed))
+	default:
+		fs.Usage()
+		return 1
+	}
+	return 0
+}
+
+func init() {
+	subcommands["toolchain"] = runToolchainCommand
+}
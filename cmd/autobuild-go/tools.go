@@ -0,0 +1,85 @@
+package main
+
+import (
+	"autobuild-go/internal/colors"
+	"autobuild-go/internal/config"
+	"autobuild-go/internal/downloader"
+	"autobuild-go/internal/golanginstaller"
+	"autobuild-go/internal/gopkginstaller"
+	"flag"
+	"fmt"
+	"path/filepath"
+)
+
+const toolsUsage = `Usage: autobuild-go tools <command> [flags]
+
+Commands:
+  list      List configured tools with requested and installed versions for the primary toolchain
+  update    Install missing tools and reinstall tools which are not pinned to a version
+
+Flags:
+`
+
+// runToolsCommand handles `autobuild-go tools ...` subcommand
+func runToolsCommand(args []string) int {
+	fs := flag.NewFlagSet("tools", flag.ExitOnError)
+	projectPath := fs.String("path", ".", "Project directory containing autobuild.yaml with tools configuration")
+	offline := fs.Bool("offline", false, "Never access the network")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), toolsUsage)
+		fs.PrintDefaults()
+	}
+
+	if len(args) == 0 {
+		fs.Usage()
+		return 1
+	}
+	command := args[0]
+	fs.Parse(args[1:])
+
+	path, err := filepath.Abs(*projectPath)
+	if err != nil {
+		colors.ErrLog("Invalid path `%s`: %v", *projectPath, err)
+		return 1
+	}
+	conf := config.GetBaseConfig(path, *offline)
+	dl, err := downloader.New(conf.Download)
+	if err != nil {
+		colors.ErrLog("Invalid download configuration: %v", err)
+		return 1
+	}
+
+	installer := golanginstaller.New(path, conf, dl)
+	if err := installer.EnsureGo(); err != nil {
+		colors.ErrLog("Error ensuring Go is installed: %v", err)
+		return 1
+	}
+	pkgInstaller := gopkginstaller.New(installer.Primary(), gopkginstaller.ConfiguredTools(conf), conf.GoNetworkEnv())
+
+	switch command {
+	case "list":
+		fmt.Printf("    %-16s %-14s %-14s %-10s %s\n", "NAME", "REQUESTED", "INSTALLED", "GO", "PACKAGE")
+		for _, tool := range pkgInstaller.List() {
+			installed, goVersion := tool.Installed, tool.GoVersion
+			if installed == "" {
+				installed, goVersion = "-", "-"
+			} else if !tool.UpToDate {
+				installed += " (stale)"
+			}
+			fmt.Printf("    %-16s %-14s %-14s %-10s %s\n", tool.Name, tool.Requested, installed, goVersion, tool.Package)
+		}
+	case "update":
+		if err := pkgInstaller.Update(); err != nil {
+			colors.ErrLog("Error installing tools: %v", err)
+			return 1
+		}
+	default:
+		fs.Usage()
+		return 1
+	}
+	return 0
+}
+
+func init() {
+	subcommands["tools"] = runToolsCommand
+}
@@ -0,0 +1,263 @@
+package builder
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"autobuild-go/internal/colors"
+	"autobuild-go/internal/models"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// archiveInfo are values available in archive `name` template
+type archiveInfo struct {
+	App     string
+	Version string
+	OS      string
+	Arch    string // Architecture with variant, e.g. armv7
+	Variant string // Build variant name
+}
+
+// archiveEntry is a file or directory stored in archive
+type archiveEntry struct {
+	name string // Slash separated path in archive, directories end with `/`
+	src  string // Source file, empty for directories
+	mode fs.FileMode
+}
+
+// archiveExec packs every built binary of the app with bundled files into tar.gz or zip archive
+func (g *GoBuilder) archiveExec(project models.Project) error {
+	conf := g.profile.Archive
+	files, err := g.bundledFiles(project)
+	if err != nil {
+		return err
+	}
+	modified := g.sourceDate(project)
+
+	// Archive paths to targets archived to them, a name template has to tell all targets apart
+	archived := map[string]GoBuilderTarget{}
+	for _, target := range g.targets {
+		tn := time.Now()
+		outputPath := filepath.Join(project.BuildDir, target.OutputName(project.AppName))
+		if _, err := os.Stat(outputPath); err != nil {
+			return fmt.Errorf("cannot archive app %s (%s), binary not built: %v", project.AppName, target, err)
+		}
+
+		name := strings.TrimSuffix(target.OutputName(project.AppName), target.EXECSUFFIX)
+		if conf.Name != "" {
+			if name, err = archiveName(conf.Name, archiveInfo{project.AppName, target.Version, target.GOOS, target.Arch(), target.BuildVariant}); err != nil {
+				return err
+			}
+		}
+		format := conf.Format
+		if format == "" {
+			format = "tar.gz"
+			if target.GOOS == "windows" {
+				format = "zip"
+			}
+		}
+
+		prefix := ""
+		if conf.Wrap == nil || *conf.Wrap {
+			prefix = name + "/"
+		}
+		entries := map[string]archiveEntry{}
+		add := func(entry archiveEntry) {
+			entry.name = prefix + entry.name
+			entries[entry.name] = entry
+			// Parent directories are stored explicitly, some tools do not create them otherwise
+			for dir := path.Dir(strings.TrimSuffix(entry.name, "/")); dir != "."; dir = path.Dir(dir) {
+				entries[dir+"/"] = archiveEntry{name: dir + "/", mode: fs.ModeDir | 0o755}
+			}
+		}
+		add(archiveEntry{name: project.AppName + target.EXECSUFFIX, src: outputPath, mode: 0o755})
+		for _, file := range files {
+			add(file)
+		}
+		sorted := make([]archiveEntry, 0, len(entries))
+		for _, entry := range entries {
+			sorted = append(sorted, entry)
+		}
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+
+		archivePath := filepath.Join(project.BuildDir, name+"."+format)
+		if other, ok := archived[archivePath]; ok {
+			return fmt.Errorf("archives of app %s for %s and %s are both named %s, use .OS, .Arch and .Variant in archive name", project.AppName, other, target, filepath.Base(archivePath))
+		}
+		archived[archivePath] = target
+		colors.Icon(colors.Yellow, "\u226b", "Archiving app "+colors.Blue+"%s"+colors.Green+" (%s)"+colors.Reset+" to %s", project.AppName, target, archivePath)
+		switch format {
+		case "tar.gz":
+			err = writeTarGz(archivePath, sorted, modified)
+		case "zip":
+			err = writeZip(archivePath, sorted, modified)
+		default:
+			return fmt.Errorf("unknown archive format `%s`, use tar.gz or zip", format)
+		}
+		if err != nil {
+			return fmt.Errorf("cannot create archive %s: %v", archivePath, err)
+		}
+
+		g.addPackaged(outputPath, archivePath)
+		g.report.addArtifact(project.AppName, "archive", target, archivePath, false)
+		g.summary.add(project.AppName, "archive (%s): %s", target, filepath.Base(archivePath))
+		colors.Success("Archived app "+colors.Blue+"`%s`"+colors.Reset+" for architecture "+colors.Green+"%s"+colors.Reset+" in "+colors.Yellow+"%.1f"+colors.Reset+" seconds, output: %s", project.AppName, target, time.Since(tn).Seconds(), archivePath)
+	}
+	return nil
+}
+
+// archiveName renders archive name template
+func archiveName(text string, info archiveInfo) (string, error) {
+	tmpl, err := template.New("archive").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid archive name template: %v", err)
+	}
+	var name bytes.Buffer
+	if err := tmpl.Execute(&name, info); err != nil {
+		return "", fmt.Errorf("invalid archive name template: %v", err)
+	}
+	if name.Len() == 0 || strings.ContainsAny(name.String(), `/\`) {
+		return "", fmt.Errorf("invalid archive name `%s`", name.String())
+	}
+	return name.String(), nil
+}
+
+// bundledFiles resolves archive `files` globs against module root. Matched directories are added with their contents
+func (g *GoBuilder) bundledFiles(project models.Project) ([]archiveEntry, error) {
+	var files []archiveEntry
+	for _, pattern := range g.profile.Archive.Files {
+		matches, err := filepath.Glob(filepath.Join(project.RootDir, filepath.FromSlash(pattern)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive file pattern `%s`: %v", pattern, err)
+		}
+		if len(matches) == 0 {
+			colors.WarnLog("Archive file pattern `%s` matches no files in %s", pattern, project.RootDir)
+		}
+		for _, match := range matches {
+			err := filepath.WalkDir(match, func(file string, entry fs.DirEntry, err error) error {
+				if err != nil || entry.IsDir() {
+					return err
+				}
+				rel, err := filepath.Rel(project.RootDir, file)
+				if err != nil {
+					return err
+				}
+				info, err := os.Stat(file)
+				if err != nil {
+					return err
+				}
+				// Only executable bit of source files is kept
+				mode := fs.FileMode(0o644)
+				if info.Mode()&0o111 != 0 {
+					mode = 0o755
+				}
+				files = append(files, archiveEntry{name: filepath.ToSlash(rel), src: file, mode: mode})
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
+}
+
+// writeTarGz writes gzip compressed tar archive of entries with fixed modification time and without owner information
+func writeTarGz(archivePath string, entries []archiveEntry, modified time.Time) error {
+	out, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		header := &tar.Header{
+			Name:    entry.name,
+			Mode:    int64(entry.mode.Perm()),
+			ModTime: modified,
+		}
+		if entry.src == "" {
+			header.Typeflag = tar.TypeDir
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			continue
+		}
+		header.Typeflag = tar.TypeReg
+		if err := writeEntry(entry.src, func(size int64) (io.Writer, error) {
+			header.Size = size
+			return tw, tw.WriteHeader(header)
+		}); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// writeZip writes zip archive of entries with fixed modification time
+func writeZip(archivePath string, entries []archiveEntry, modified time.Time) error {
+	out, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Modified: modified.UTC(), Method: zip.Deflate}
+		header.SetMode(entry.mode)
+		if entry.src == "" {
+			header.Method = zip.Store
+			if _, err := zw.CreateHeader(header); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := writeEntry(entry.src, func(int64) (io.Writer, error) {
+			return zw.CreateHeader(header)
+		}); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// writeEntry copies src into writer created for its size
+func writeEntry(src string, create func(size int64) (io.Writer, error)) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	writer, err := create(info.Size())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, in)
+	return err
+}
@@ -0,0 +1,419 @@
+package builder
+
+import (
+	"autobuild-go/internal/colors"
+	"autobuild-go/internal/downloader"
+	"autobuild-go/internal/models"
+	"autobuild-go/internal/remotecache"
+	"autobuild-go/internal/utils"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// cacheFormat is part of every input hash, changing it invalidates existing cache entries
+const cacheFormat = "autobuild-go build cache v1"
+
+// cacheArtifact is file name of the artifact inside cache entry, checksums are stored next to it with hasher suffix
+const cacheArtifact = "artifact"
+
+// ignoredCacheEnv are environment variables which do not change built artifacts
+var ignoredCacheEnv = map[string]bool{
+	"GOPATH":      true,
+	"GOROOT":      true,
+	"GOCACHE":     true,
+	"GOMODCACHE":  true,
+	"GOPROXY":     true,
+	"GOSUMDB":     true,
+	"GONOSUMDB":   true,
+	"GONOPROXY":   true,
+	"GOPRIVATE":   true,
+	"GOINSECURE":  true,
+	"GOTOOLCHAIN": true,
+	"GOENV":       true,
+	"GOTMPDIR":    true,
+	"GOCACHEPROG": true,
+}
+
+// cachedPackage is a part of `go list -json` output describing inputs of a package
+type cachedPackage struct {
+	ImportPath string
+	Dir        string
+	Standard   bool
+	Module     *struct {
+		Path    string
+		Version string
+		Main    bool
+		Replace *struct {
+			Path string
+		}
+	}
+	GoFiles    []string
+	CgoFiles   []string
+	CFiles     []string
+	CXXFiles   []string
+	MFiles     []string
+	HFiles     []string
+	FFiles     []string
+	SFiles     []string
+	SysoFiles  []string
+	SwigFiles  []string
+	EmbedFiles []string
+	Error      *struct {
+		Err string
+	}
+}
+
+// buildCache stores built artifacts and their checksums under hash of build inputs.
+// Entries missing locally are fetched from remote cache, new entries are uploaded to it
+type buildCache struct {
+	dir    string
+	remote *remotecache.Client
+}
+
+// newBuildCache returns build cache configured in conf, nil when caching is disabled
+func newBuildCache(conf models.SelectedConfig) *buildCache {
+	if conf.Cache.Disabled {
+		return nil
+	}
+	cache := &buildCache{dir: cacheDir(conf)}
+
+	if conf.Cache.Remote != "" && conf.Offline {
+		colors.InfoLog("Remote cache is not used in offline mode")
+	} else if conf.Cache.Remote != "" {
+		dl, err := downloader.New(conf.Download)
+		if err != nil {
+			colors.WarnLog("Remote cache is not used: %v", err)
+		} else {
+			cache.remote = remotecache.New(conf.Cache, dl.Client())
+		}
+	}
+	return cache
+}
+
+// cacheDir returns local cache directory, `build-cache` in toolchain location by default
+func cacheDir(conf models.SelectedConfig) string {
+	if conf.Cache.Dir != "" {
+		return conf.Cache.Dir
+	}
+	return filepath.Join(conf.Toolchain.Location, "build-cache")
+}
+
+// entryDir returns directory of cache entry for input hash
+func (c *buildCache) entryDir(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// restore copies cached artifact and its checksums (`<artifact><suffix>`) to outputPath.
+// Returns false when there is no entry for the key, neither local nor remote
+func (c *buildCache) restore(key string, outputPath string, checksumSuffixes []string) (bool, error) {
+	entry := c.entryDir(key)
+	if _, err := os.Stat(filepath.Join(entry, cacheArtifact)); os.IsNotExist(err) {
+		if c.remote == nil || !c.fetch(key, checksumSuffixes) {
+			return false, nil
+		}
+	}
+	files, err := os.ReadDir(entry)
+	if err != nil {
+		return false, err
+	}
+
+	// Checksums left by earlier builds may describe other contents, only ones restored from the entry are kept
+	for _, suffix := range checksumSuffixes {
+		if err := os.Remove(outputPath + suffix); err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+	for _, file := range files {
+		if !strings.HasPrefix(file.Name(), cacheArtifact) {
+			continue
+		}
+		dest := outputPath + strings.TrimPrefix(file.Name(), cacheArtifact)
+		if err := copyFile(filepath.Join(entry, file.Name()), dest); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// fetch downloads entry from remote cache
+func (c *buildCache) fetch(key string, checksumSuffixes []string) bool {
+	entry := c.entryDir(key)
+	if err := os.MkdirAll(filepath.Dir(entry), os.ModePerm); err != nil {
+		return false
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(entry), key+".tmp")
+	if err != nil {
+		return false
+	}
+	defer os.RemoveAll(tmp)
+
+	if !c.remote.Get(key, cacheArtifact, filepath.Join(tmp, cacheArtifact)) {
+		return false
+	}
+	// Artifacts are executables
+	if err := os.Chmod(filepath.Join(tmp, cacheArtifact), 0o755); err != nil {
+		return false
+	}
+	for _, suffix := range checksumSuffixes {
+		c.remote.Get(key, cacheArtifact+suffix, filepath.Join(tmp, cacheArtifact+suffix))
+	}
+	os.RemoveAll(entry)
+	return os.Rename(tmp, entry) == nil
+}
+
+// store records built artifact under the key. Entry is prepared in temporary directory and renamed,
+// so concurrent runs never see partial entries
+func (c *buildCache) store(key string, outputPath string) error {
+	entry := c.entryDir(key)
+	if _, err := os.Stat(entry); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(entry), os.ModePerm); err != nil {
+		return err
+	}
+
+	tmp, err := os.MkdirTemp(filepath.Dir(entry), key+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if err := copyFile(outputPath, filepath.Join(tmp, cacheArtifact)); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, entry); err != nil && !os.IsExist(err) {
+		// Another run stored the same entry in the meantime
+		if _, statErr := os.Stat(entry); statErr != nil {
+			return err
+		}
+	}
+	if c.remote != nil {
+		c.remote.Put(key, cacheArtifact, filepath.Join(entry, cacheArtifact))
+	}
+	return nil
+}
+
+// storeChecksum adds checksum file of the artifact (named `<artifact>.<hasher>`) to the cache entry
+func (c *buildCache) storeChecksum(key string, checksumPath string, suffix string) error {
+	entry := c.entryDir(key)
+	if _, err := os.Stat(entry); err != nil {
+		return err
+	}
+	if err := copyFile(checksumPath, filepath.Join(entry, cacheArtifact+suffix)); err != nil {
+		return err
+	}
+	if c.remote != nil {
+		c.remote.Put(key, cacheArtifact+suffix, checksumPath)
+	}
+	return nil
+}
+
+// copyFile copies file contents preserving its permissions, destination is replaced atomically
+func copyFile(src string, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(out.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), dest)
+}
+
+// inputHash computes hash of everything the artifact of project built for target depends on:
+// source files of all packages from `go list -deps`, go.mod and go.sum, Go version, target, build flags and environment
+func (g *GoBuilder) inputHash(project models.Project, target GoBuilderTarget, flags []string, env []string) (string, error) {
+	hasher := sha256.New()
+	fmt.Fprintf(hasher, "%s\ngo %s\ntarget %s/%s\nvariant %s\n", cacheFormat, g.toolchain.Version, target.GOOS, target.Arch(), target.BuildVariant)
+	for _, flag := range flags {
+		fmt.Fprintf(hasher, "flag %s\n", flag)
+	}
+	for _, variable := range g.variantEnv(target) {
+		fmt.Fprintf(hasher, "variant env %s\n", variable)
+	}
+
+	// Later values override earlier ones, like in exec.Cmd
+	values := map[string]string{}
+	for _, variable := range env {
+		if key, value, ok := strings.Cut(variable, "="); ok {
+			values[key] = value
+		}
+	}
+	var keys []string
+	for key := range values {
+		if (strings.HasPrefix(key, "GO") || strings.HasPrefix(key, "CGO_") || key == "CC" || key == "CXX" || key == "PKG_CONFIG") && !ignoredCacheEnv[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(hasher, "env %s=%s\n", key, values[key])
+	}
+
+	// VCS stamp of the binary (-buildvcs=auto) describes the commit and worktree state
+	if !strings.Contains(strings.Join(flags, " ")+" "+values["GOFLAGS"], "-buildvcs=false") {
+		if info := g.moduleGitInfo(project); info.commit != "" {
+			fmt.Fprintf(hasher, "vcs %s %s modified=%t\n", info.commit, info.commitDate, info.modified)
+		}
+	}
+
+	for _, name := range []string{"go.mod", "go.sum"} {
+		if err := hashFile(hasher, project.RootDir, filepath.Join(project.RootDir, name)); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	args := append([]string{"list", "-deps", "-json"}, flags...)
+	cmd := exec.Command(g.toolchain.GoExec(), append(args, project.AppMainSrcDir)...)
+	cmd.Dir = project.RootDir
+	cmd.Env = env
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("go list failed: %v: %s", err, strings.TrimSpace(errBuf.String()))
+	}
+
+	decoder := json.NewDecoder(&outBuf)
+	for {
+		var pkg cachedPackage
+		if err := decoder.Decode(&pkg); err == io.EOF {
+			break
+		} else if err != nil {
+			return "", fmt.Errorf("cannot parse go list output: %v", err)
+		}
+		if pkg.Error != nil {
+			return "", fmt.Errorf("package %s: %s", pkg.ImportPath, pkg.Error.Err)
+		}
+		// Standard library is identified by Go version
+		if pkg.Standard {
+			continue
+		}
+		fmt.Fprintf(hasher, "package %s\n", pkg.ImportPath)
+
+		// Module cache contents are immutable, version identifies the sources
+		if pkg.Module != nil && !pkg.Module.Main && pkg.Module.Replace == nil && pkg.Module.Version != "" {
+			fmt.Fprintf(hasher, "module %s@%s\n", pkg.Module.Path, pkg.Module.Version)
+			continue
+		}
+
+		var files []string
+		for _, group := range [][]string{pkg.GoFiles, pkg.CgoFiles, pkg.CFiles, pkg.CXXFiles, pkg.MFiles, pkg.HFiles, pkg.FFiles, pkg.SFiles, pkg.SysoFiles, pkg.SwigFiles, pkg.EmbedFiles} {
+			files = append(files, group...)
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			if err := hashFile(hasher, project.RootDir, filepath.Join(pkg.Dir, file)); err != nil {
+				return "", err
+			}
+		}
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// hashFile writes path relative to root and hash of file contents to hasher
+func hashFile(hasher io.Writer, root string, path string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+	sum := sha256.Sum256(contents)
+	_, err = fmt.Fprintf(hasher, "file %s %s\n", filepath.ToSlash(rel), hex.EncodeToString(sum[:]))
+	return err
+}
+
+// goCacheEnv returns environment making the go command share its build cache through `autobuild-go cache-prog`
+// when `cache.go_cache` is enabled
+func goCacheEnv(toolchain models.GoToolchain, conf models.SelectedConfig) []string {
+	if !conf.Cache.GoCache || conf.Cache.Disabled {
+		return nil
+	}
+	if utils.CompareVersions(toolchain.Version, "1.24") < 0 {
+		colors.WarnLog("Go build cache is not shared, GOCACHEPROG requires Go 1.24 or newer (using %s)", toolchain.Version)
+		return nil
+	}
+	self, err := os.Executable()
+	if err != nil {
+		colors.WarnLog("Go build cache is not shared: %v", err)
+		return nil
+	}
+
+	args := []string{self, "cache-prog", "--dir", filepath.Join(cacheDir(conf), "gocache")}
+	if conf.Cache.Remote != "" && !conf.Offline {
+		args = append(args, "--remote", conf.Cache.Remote)
+	}
+	if conf.Cache.ReadOnly {
+		args = append(args, "--read-only")
+	}
+	// GOCACHEPROG is split like a shell command line, paths with spaces have to be quoted
+	for i, arg := range args {
+		if strings.ContainsAny(arg, " \t") {
+			args[i] = "'" + arg + "'"
+		}
+	}
+
+	// Credentials are passed through environment to keep them out of process list
+	env := []string{"GOCACHEPROG=" + strings.Join(args, " ")}
+	for name, value := range map[string]string{
+		"AUTOBUILD_CACHE_TOKEN":    conf.Cache.Token,
+		"AUTOBUILD_CACHE_USERNAME": conf.Cache.Username,
+		"AUTOBUILD_CACHE_PASSWORD": conf.Cache.Password,
+	} {
+		if value != "" {
+			env = append(env, name+"="+os.ExpandEnv(value))
+		}
+	}
+	// Downloader settings, so the remote cache is reached through the same proxy and trusted authorities.
+	// CA file is resolved here, the go command starts cache-prog in the module directory
+	caFile := conf.Download.CAFile
+	if caFile != "" {
+		if abs, err := filepath.Abs(caFile); err == nil {
+			caFile = abs
+		}
+	}
+	for name, value := range map[string]string{
+		"AUTOBUILD_DOWNLOAD_PROXY":   conf.Download.Proxy,
+		"AUTOBUILD_DOWNLOAD_CA_FILE": caFile,
+		"AUTOBUILD_DOWNLOAD_TIMEOUT": conf.Download.Timeout,
+	} {
+		if value != "" {
+			env = append(env, name+"="+value)
+		}
+	}
+	if conf.Download.Retries != nil {
+		env = append(env, "AUTOBUILD_DOWNLOAD_RETRIES="+strconv.Itoa(*conf.Download.Retries))
+	}
+	colors.InfoLog("Go build cache is kept by GOCACHEPROG: "+colors.Blue+"autobuild-go %s"+colors.Reset, strings.Join(args[1:], " "))
+	return env
+}
@@ -0,0 +1,284 @@
+package builder
+
+import (
+	"autobuild-go/internal/colors"
+	"autobuild-go/internal/models"
+	"autobuild-go/internal/utils"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// moduleStage memoizes result of a stage which runs once per module, shared by all apps of the module
+type moduleStage struct {
+	once sync.Once
+	err  error
+}
+
+// onceForModule runs fn once per stage and module root. Concurrent callers wait for the first run,
+// apps which did not run the stage themselves get a short error instead of a repeated report
+func (g *GoBuilder) onceForModule(stage string, project models.Project, fn func() error) error {
+	value, _ := g.moduleStages.LoadOrStore(stage+"|"+project.RootDir, &moduleStage{})
+	result := value.(*moduleStage)
+
+	ran := false
+	result.once.Do(func() {
+		ran = true
+		result.err = fn()
+	})
+	if result.err != nil && !ran {
+		return fmt.Errorf("skipping app %s, %s check of module %s failed", project.AppName, stage, project.RootDir)
+	}
+	return result.err
+}
+
+// moduleSummary returns run summary key of module stages
+func moduleSummary(project models.Project) string {
+	return "module " + project.RootDir
+}
+
+// moduleFileName returns name of module used in report file names, directory name with a hash of module root,
+// e.g. api-3f2a9c1e, so modules in equally named directories do not overwrite each other's reports
+func moduleFileName(project models.Project) string {
+	sum := sha256.Sum256([]byte(project.RootDir))
+	return filepath.Base(project.RootDir) + "-" + hex.EncodeToString(sum[:4])
+}
+
+// runCheck runs go toolchain command in module root and returns combined output
+func (g *GoBuilder) runCheck(project models.Project, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = project.RootDir
+	cmd.Env = g.defaultEnv
+
+	var outBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &outBuf
+	err := cmd.Run()
+	return outBuf.String(), err
+}
+
+// reportLines prints every non-empty line of tool output as error
+func reportLines(output string) {
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			colors.ErrLog("%s", line)
+		}
+	}
+}
+
+// vetExec runs `go vet ./...` once per module
+func (g *GoBuilder) vetExec(project models.Project) error {
+	return g.onceForModule("vet", project, func() error {
+		tn := time.Now()
+		colors.Icon(colors.Yellow, "\u226b", "Vetting module "+colors.Blue+"%s"+colors.Reset, project.RootDir)
+
+		output, err := g.runCheck(project, g.toolchain.GoExec(), "vet", "./...")
+		if err != nil {
+			reportLines(output)
+			g.summary.add(moduleSummary(project), "vet: failed")
+			return fmt.Errorf("go vet reported problems in %s: %v", project.RootDir, err)
+		}
+		colors.Success("Successfully vetted module "+colors.Blue+"`%s`"+colors.Reset+" in "+colors.Yellow+"%.1f"+colors.Reset+" seconds", project.RootDir, time.Since(tn).Seconds())
+		return nil
+	})
+}
+
+// fmtExec lists files not formatted with gofmt once per module. In fix mode files are rewritten
+func (g *GoBuilder) fmtExec(project models.Project) error {
+	return g.onceForModule("fmt", project, func() error {
+		tn := time.Now()
+		colors.Icon(colors.Yellow, "\u226b", "Checking formatting of module "+colors.Blue+"%s"+colors.Reset, project.RootDir)
+
+		files, err := moduleGoFiles(project.RootDir)
+		if err != nil {
+			return fmt.Errorf("cannot list Go files of %s: %v", project.RootDir, err)
+		}
+		if len(files) == 0 {
+			return nil
+		}
+
+		gofmt := filepath.Join(g.toolchain.GoRoot(), "bin", "gofmt")
+		if runtime.GOOS == "windows" {
+			gofmt += ".exe"
+		}
+
+		output, err := g.runCheck(project, gofmt, append([]string{"-l"}, files...)...)
+		if err != nil {
+			reportLines(output)
+			return fmt.Errorf("gofmt failed in %s: %v", project.RootDir, err)
+		}
+
+		unformatted := strings.Fields(output)
+		if len(unformatted) == 0 {
+			colors.Success("Module "+colors.Blue+"`%s`"+colors.Reset+" is formatted, checked in "+colors.Yellow+"%.1f"+colors.Reset+" seconds", project.RootDir, time.Since(tn).Seconds())
+			return nil
+		}
+
+		if g.fix {
+			if output, err := g.runCheck(project, gofmt, append([]string{"-w"}, unformatted...)...); err != nil {
+				reportLines(output)
+				return fmt.Errorf("gofmt cannot rewrite files in %s: %v", project.RootDir, err)
+			}
+			for _, file := range unformatted {
+				colors.Success("Formatted %s", file)
+			}
+			g.summary.add(moduleSummary(project), "fmt: %d file(s) rewritten", len(unformatted))
+			return nil
+		}
+
+		for _, file := range unformatted {
+			colors.ErrLog("Not formatted: %s", file)
+		}
+		g.summary.add(moduleSummary(project), "fmt: %d file(s) not formatted", len(unformatted))
+		return fmt.Errorf("%d file(s) not formatted in %s, run with --fix to rewrite them", len(unformatted), project.RootDir)
+	})
+}
+
+// tidyExec checks that go.mod and go.sum are tidy once per module. In fix mode `go mod tidy` result is kept
+func (g *GoBuilder) tidyExec(project models.Project) error {
+	return g.onceForModule("tidy", project, func() error {
+		tn := time.Now()
+		colors.Icon(colors.Yellow, "\u226b", "Checking go.mod of module "+colors.Blue+"%s"+colors.Reset, project.RootDir)
+
+		if g.fix {
+			if output, err := g.runCheck(project, g.toolchain.GoExec(), "mod", "tidy"); err != nil {
+				reportLines(output)
+				return fmt.Errorf("go mod tidy failed in %s: %v", project.RootDir, err)
+			}
+			colors.Success("Tidied module "+colors.Blue+"`%s`"+colors.Reset, project.RootDir)
+			return nil
+		}
+
+		var diff string
+		var err error
+		if utils.CompareVersions(g.toolchain.Version, "1.23") >= 0 {
+			// `go mod tidy -diff` does not touch files and exits with error when they are not tidy
+			diff, err = g.runCheck(project, g.toolchain.GoExec(), "mod", "tidy", "-diff")
+			if err != nil && !strings.Contains(diff, "---") {
+				reportLines(diff)
+				return fmt.Errorf("go mod tidy failed in %s: %v", project.RootDir, err)
+			}
+		} else {
+			diff, err = g.tidyDiff(project)
+			if err != nil {
+				return err
+			}
+		}
+
+		if strings.TrimSpace(diff) == "" {
+			colors.Success("Module "+colors.Blue+"`%s`"+colors.Reset+" is tidy, checked in "+colors.Yellow+"%.1f"+colors.Reset+" seconds", project.RootDir, time.Since(tn).Seconds())
+			return nil
+		}
+
+		reportLines(diff)
+		g.summary.add(moduleSummary(project), "tidy: go.mod/go.sum not tidy")
+		return fmt.Errorf("go.mod or go.sum is not tidy in %s, run with --fix to update them", project.RootDir)
+	})
+}
+
+// tidyDiff runs `go mod tidy` on toolchains without `-diff` support, restores original files and returns changed lines
+func (g *GoBuilder) tidyDiff(project models.Project) (string, error) {
+	files := []string{"go.mod", "go.sum"}
+	original := map[string][]byte{}
+	for _, file := range files {
+		if contents, err := os.ReadFile(filepath.Join(project.RootDir, file)); err == nil {
+			original[file] = contents
+		}
+	}
+
+	output, tidyErr := g.runCheck(project, g.toolchain.GoExec(), "mod", "tidy")
+
+	var diff strings.Builder
+	for _, file := range files {
+		path := filepath.Join(project.RootDir, file)
+		tidied, _ := os.ReadFile(path)
+		before, existed := original[file]
+		if bytes.Equal(before, tidied) {
+			continue
+		}
+		diff.WriteString(lineDiff(file, string(before), string(tidied)))
+
+		if existed {
+			os.WriteFile(path, before, 0o644)
+		} else {
+			os.Remove(path)
+		}
+	}
+
+	if tidyErr != nil {
+		reportLines(output)
+		return "", fmt.Errorf("go mod tidy failed in %s: %v", project.RootDir, tidyErr)
+	}
+	return diff.String(), nil
+}
+
+// lineDiff returns lines removed from and added to file, prefixed with - and +
+func lineDiff(file, before, after string) string {
+	count := func(text string) map[string]int {
+		lines := map[string]int{}
+		for _, line := range strings.Split(text, "\n") {
+			if strings.TrimSpace(line) != "" {
+				lines[line]++
+			}
+		}
+		return lines
+	}
+	beforeLines, afterLines := count(before), count(after)
+
+	var diff strings.Builder
+	for _, line := range strings.Split(before, "\n") {
+		if beforeLines[line] > afterLines[line] {
+			diff.WriteString(fmt.Sprintf("%s: -%s\n", file, line))
+			beforeLines[line]--
+		}
+	}
+	beforeLines = count(before)
+	for _, line := range strings.Split(after, "\n") {
+		if afterLines[line] > beforeLines[line] {
+			diff.WriteString(fmt.Sprintf("%s: +%s\n", file, line))
+			afterLines[line]--
+		}
+	}
+	return diff.String()
+}
+
+// moduleGoFiles lists Go files of module rooted at root, relative to it. Vendor, testdata, hidden directories
+// and nested modules are skipped, like `go` command does
+func moduleGoFiles(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != root {
+				if name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+					return filepath.SkipDir
+				}
+				if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		if strings.HasSuffix(d.Name(), ".go") {
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files, err
+}
@@ -2,6 +2,8 @@ package builder
 
 import (
 	"autobuild-go/internal/colors"
+	"autobuild-go/internal/findings"
+	"autobuild-go/internal/gopkginstaller"
 	"autobuild-go/internal/models"
 	"bytes"
 	"crypto/sha1"
@@ -13,7 +15,6 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
 	"strings"
 	"sync"
 	"time"
@@ -22,36 +23,115 @@ import (
 type GoBuilderTargets []GoBuilderTarget
 
 type GoBuilderTarget struct {
-	GOOS       string
-	GOARCH     string
-	EXECSUFFIX string
+	GOOS         string
+	GOARCH       string
+	EXECSUFFIX   string
+	VariantEnv   string // Microarchitecture variable, e.g. GOARM
+	Variant      string // Value of VariantEnv, e.g. 7
+	BuildVariant string // Name of build variant from profile `variants`
+	Version      string // Version carried in artifact names, set when profile declares `version`
+}
+
+// OutputName returns file name of the app binary built for target, e.g. app-linux-amd64 or app-enterprise-1.2.0-linux-amd64
+func (t GoBuilderTarget) OutputName(appName string) string {
+	if t.BuildVariant != "" {
+		appName += "-" + t.BuildVariant
+	}
+	if t.Version != "" {
+		appName += "-" + strings.NewReplacer("/", "-", "\\", "-").Replace(t.Version)
+	}
+	return fmt.Sprintf("%s-%s-%s%s", appName, t.GOOS, t.Arch(), t.EXECSUFFIX)
 }
 
 type GoBuilder struct {
-	toolchainPath  string
-	goRootPath     string
-	goPathPath     string
+	toolchain      models.GoToolchain
 	targets        GoBuilderTargets
 	defaultEnv     []string
 	hashers        map[string]hash.Hash
 	stages         map[string]bool
 	currentRelease string
+	baseEnv        []string
+	testToolchains []models.GoToolchain
+	tools          map[string]string
+	profile        models.Profile
+	summary        *runSummary
+	fix            bool
+	moduleStages   sync.Map
+	offline        bool
+	findings       *findings.Collector
+	testHistory    *testHistory
+	changedSince   string
+	testImpacts    sync.Map
+	cache          *buildCache
+	cacheKeys      sync.Map // Artifact path to input hash of its build
+	cacheHits      sync.Map // Artifact paths restored from cache
+	profileName    string
+	buildDate      time.Time
+	gitInfos       sync.Map // Module root to its git state
+	injectChecks   sync.Map // Injected variables checked in app sources
+	report         *buildReport
+	packaged       sync.Map // Binary path to paths of archives and packages made of it
+}
+
+// toolCommand returns command running tool in project: `go tool <pkg>` when project go.mod declares the tool,
+// otherwise the binary installed by the tool installer
+func (g *GoBuilder) toolCommand(project models.Project, name string) (string, []string) {
+	for _, pkg := range project.Tools {
+		if strings.TrimSuffix(gopkginstaller.BinaryName(pkg), ".exe") == name {
+			colors.InfoLog("Using "+colors.Blue+"go tool %s"+colors.Reset+" declared in go.mod of "+colors.Blue+"%s"+colors.Reset, pkg, project.AppName)
+			return g.toolchain.GoExec(), []string{"tool", pkg}
+		}
+	}
+
+	pkg, ok := g.tools[name]
+	if !ok {
+		pkg = name
+	}
+	return gopkginstaller.ToolPath(g.toolchain, name, pkg), nil
+}
+
+// toolchainEnv returns environment pointing GOROOT, GOPATH and PATH at given toolchain
+func (g *GoBuilder) toolchainEnv(toolchain models.GoToolchain) []string {
+	env := append([]string{}, g.baseEnv...)
+	env = append(env, fmt.Sprintf("GOPATH=%s", toolchain.GoPath()))
+	env = append(env, fmt.Sprintf("GOROOT=%s", toolchain.GoRoot()))
+	env = append(env, fmt.Sprintf("PATH=%s%c%s", filepath.Join(toolchain.GoRoot(), "bin"), os.PathListSeparator, os.Getenv("PATH")))
+	return env
 }
 
 func (g *GoBuilder) Build(projectsSource chan models.Project) {
-	g.defaultEnv = append(g.defaultEnv, fmt.Sprintf("GOPATH=%s", g.goPathPath))
-	g.defaultEnv = append(g.defaultEnv, fmt.Sprintf("GOROOT=%s", g.goRootPath))
-	g.defaultEnv = append(g.defaultEnv, fmt.Sprintf("PATH=%s%c%s", filepath.Join(g.goRootPath, "bin"), os.PathListSeparator, os.Getenv("PATH")))
+	g.defaultEnv = g.toolchainEnv(g.toolchain)
 
 	wg := sync.WaitGroup{}
+	buildDir := ""
 	for project := range projectsSource {
+		buildDir = project.BuildDir
 		wg.Add(1)
 		go func(project models.Project) {
 			defer wg.Done()
+			for _, check := range []struct {
+				stage string
+				exec  func(models.Project) error
+			}{
+				{"tidy", g.tidyExec},
+				{"fmt", g.fmtExec},
+				{"vet", g.vetExec},
+				{"lint", g.lintExec},
+			} {
+				if _, ok := g.stages[check.stage]; ok {
+					if err := check.exec(project); err != nil {
+						colors.ErrLog("Error: %v", err)
+						return
+					}
+				}
+			}
+
 			if _, ok := g.stages["test"]; ok {
-				if err := g.testExec(project); err != nil {
-					colors.ErrLog("Error: %v", err)
-					return
+				for _, toolchain := range g.testToolchains {
+					if err := g.testExec(project, toolchain); err != nil {
+						colors.ErrLog("Error: %v", err)
+						return
+					}
 				}
 			}
 
@@ -69,6 +149,43 @@ func (g *GoBuilder) Build(projectsSource chan models.Project) {
 				}
 			}
 
+			if _, ok := g.stages["verify-repro"]; ok {
+				if err := g.verifyReproExec(project); err != nil {
+					colors.ErrLog("Error: %v", err)
+					return
+				}
+			}
+
+			if _, ok := g.stages["archive"]; ok {
+				if err := g.archiveExec(project); err != nil {
+					colors.ErrLog("Error archiving app %s: %v", project.AppName, err)
+					return
+				}
+			}
+
+			for _, format := range []string{"deb", "rpm"} {
+				if _, ok := g.stages[format]; ok {
+					if err := g.packageExec(project, format); err != nil {
+						colors.ErrLog("Error: %v", err)
+						return
+					}
+				}
+			}
+
+			if _, ok := g.stages["oci"]; ok {
+				if err := g.ociExec(project); err != nil {
+					colors.ErrLog("Error: %v", err)
+					return
+				}
+			}
+
+			if _, ok := g.stages["vuln"]; ok {
+				if err := g.vulnExec(project); err != nil {
+					colors.ErrLog("Error: %v", err)
+					return
+				}
+			}
+
 			if _, ok := g.stages["hash"]; ok {
 				if err := g.sumExec(project); err != nil {
 					colors.ErrLog("Error creating SHA-256 sum for app %s: %v", project.AppName, err)
@@ -78,101 +195,95 @@ func (g *GoBuilder) Build(projectsSource chan models.Project) {
 		}(project)
 	}
 	wg.Wait()
-}
 
-func (g *GoBuilder) testExec(project models.Project) error {
-	tn := time.Now()
-	colors.Icon(colors.Yellow, "\u226b", "Testing app "+colors.Blue+"%s"+colors.Reset, project.AppName)
-
-	// Prepare the build command: go build -o outputPath project.AppMainSrcDir
-	cmd := exec.Command(filepath.Join(g.goRootPath, "bin", "go"), "test", "-v", fmt.Sprintf("-coverprofile=%s/coverage-%s.txt", project.BuildDir, project.AppName), "./...")
-	cmd.Dir = project.RootDir
-	cmd.Env = g.defaultEnv
-
-	// Capture output
-	var outBuf, errBuf bytes.Buffer
-	cmd.Stdout = &outBuf
-	cmd.Stderr = &errBuf
-
-	// Execute the command
-	if err := cmd.Run(); err != nil {
-		// If there's an error, return the captured stdout and stderr as part of the error
-		persistLog("test-", outBuf, errBuf, project.BuildDir, project.AppName)
-		return fmt.Errorf("error testing %s: %v. Logs created", project.AppName, err)
+	if err := g.testHistory.save(); err != nil {
+		colors.ErrLog("Cannot save test history: %v", err)
 	}
-	colors.Success("Successfully tested application "+colors.Blue+"`%s`"+colors.Reset+" in "+colors.Yellow+"%.1f"+colors.Reset+" seconds", project.AppName, time.Since(tn).Seconds())
-
-	return nil
-}
-
-func (g *GoBuilder) gosecExec(project models.Project) error {
-	tn := time.Now()
-	colors.Icon(colors.Yellow, "\u226b", "Go security check of "+colors.Blue+"%s"+colors.Reset+" app", project.AppName)
-
-	suffix := ""
-	if runtime.GOOS == "windows" {
-		suffix = ".exe"
-	}
-
-	// Prepare the build command: go build -o outputPath project.AppMainSrcDir
-	cmd := exec.Command(filepath.Join(g.goPathPath, "bin", "gosec"+suffix), "./...")
-	cmd.Dir = project.RootDir
-	cmd.Env = g.defaultEnv
-
-	// Capture output
-	var outBuf, errBuf bytes.Buffer
-	cmd.Stdout = &outBuf
-	cmd.Stderr = &errBuf
-
-	// Execute the command
-	if err := cmd.Run(); err != nil {
-		// If there's an error, return the captured stdout and stderr as part of the error
-		persistLog("gosec-", outBuf, errBuf, project.BuildDir, project.AppName)
-		return fmt.Errorf("security error %s: %v. Logs created", project.AppName, err)
+	g.testHistory.summarize(g.summary)
+
+	if buildDir != "" {
+		sarifPath := filepath.Join(buildDir, "findings.sarif")
+		if written, err := g.findings.WriteSARIF(sarifPath); err != nil {
+			colors.ErrLog("Cannot write combined SARIF report `%s`: %v", sarifPath, err)
+		} else if written {
+			colors.InfoLog("Combined findings stored in %s", sarifPath)
+		}
+		reportPath := filepath.Join(buildDir, "build-report.json")
+		if written, err := g.report.write(reportPath); err != nil {
+			colors.ErrLog("Cannot write build report `%s`: %v", reportPath, err)
+		} else if written {
+			colors.InfoLog("Build report stored in %s", reportPath)
+		}
 	}
-	colors.Success("Successfully checked application "+colors.Blue+"`%s`"+colors.Reset+" in "+colors.Yellow+"%.1f"+colors.Reset+" seconds", project.AppName, time.Since(tn).Seconds())
-
-	return nil
+	g.summary.print()
 }
 
 func (g *GoBuilder) sumExec(project models.Project) error {
 	for _, target := range g.targets {
-		outputName := fmt.Sprintf("%s-%s-%s%s", project.AppName, target.GOOS, target.GOARCH, target.EXECSUFFIX)
-		outputPath := filepath.Join(project.BuildDir, outputName)
-
-		if _, err := os.Lstat(outputPath); os.IsNotExist(err) {
-			colors.ErrLog("Application build in `%s` does not exist! Failed to generate SHA sums", outputPath)
-			continue
-		}
-
-		contents, err := os.ReadFile(outputPath)
-		if err != nil {
-			colors.ErrLog("Cannot open build from `%s`: %v! Failed to generate SHA sums", outputPath, err)
-			continue
+		binaryPath := filepath.Join(project.BuildDir, target.OutputName(project.AppName))
+		outputPaths := []string{binaryPath}
+		if packaged, ok := g.packaged.Load(binaryPath); ok {
+			outputPaths = append(outputPaths, *packaged.(*[]string)...)
 		}
 
-		for hasherLabel, hasher := range g.hashers {
-			tn := time.Now()
-			hasherLabelUpper := strings.ToUpper(hasherLabel)
-			colors.Icon(colors.Yellow, "\u226b", "Generating %s Sum for app "+colors.Blue+"%s"+colors.Green+" (%s:%s)"+colors.Reset+" to %s", hasherLabelUpper, project.AppName+target.EXECSUFFIX+".sha265", target.GOOS, target.GOARCH, project.BuildDir)
-			outputShaSum := outputPath + "." + hasherLabel
-
-			if _, err := hasher.Write(contents); err != nil {
-				colors.ErrLog("Cannot generate `%s` sum from `%s`: %v! Failed to generate SHA sums", hasherLabelUpper, outputPath, err)
+		for _, outputPath := range outputPaths {
+			if _, err := os.Lstat(outputPath); os.IsNotExist(err) {
+				colors.ErrLog("Application build in `%s` does not exist! Failed to generate SHA sums", outputPath)
 				continue
 			}
-			buildSumHex := hex.EncodeToString(hasher.Sum(nil))
-			hasher.Reset()
-			if err := os.WriteFile(outputShaSum, []byte(buildSumHex), os.ModePerm); err != nil {
-				colors.ErrLog("Cannot write %s sum file in `%s`: %v! Failed to generate SHA-256 sum", hasherLabelUpper, outputShaSum, err)
+
+			contents, err := os.ReadFile(outputPath)
+			if err != nil {
+				colors.ErrLog("Cannot open build from `%s`: %v! Failed to generate SHA sums", outputPath, err)
 				continue
 			}
-			colors.Success("Generated "+colors.Green+"%s"+colors.Reset+" app "+colors.Blue+"`%s`"+colors.Reset+" for architecture "+colors.Green+"%s:%s"+colors.Reset+" in "+colors.Yellow+"%.1f"+colors.Reset+" seconds, output: %s", buildSumHex, project.AppName, target.GOOS, target.GOARCH, time.Since(tn).Seconds(), outputPath)
+
+			key, _ := g.cacheKeys.Load(outputPath)
+			for hasherLabel, hasher := range g.hashers {
+				tn := time.Now()
+				hasherLabelUpper := strings.ToUpper(hasherLabel)
+				outputShaSum := outputPath + "." + hasherLabel
+
+				// Checksums restored together with the artifact are reused
+				if _, hit := g.cacheHits.Load(outputPath); hit {
+					if _, err := os.Stat(outputShaSum); err == nil {
+						colors.Success("Restored %s sum of app "+colors.Blue+"`%s`"+colors.Reset+" for architecture "+colors.Green+"%s"+colors.Reset+" from build cache, output: %s", hasherLabelUpper, project.AppName, target, outputShaSum)
+						continue
+					}
+				}
+
+				colors.Icon(colors.Yellow, "\u226b", "Generating %s Sum for app "+colors.Blue+"%s"+colors.Green+" (%s)"+colors.Reset+" to %s", hasherLabelUpper, project.AppName+target.EXECSUFFIX+".sha265", target, project.BuildDir)
+				if _, err := hasher.Write(contents); err != nil {
+					colors.ErrLog("Cannot generate `%s` sum from `%s`: %v! Failed to generate SHA sums", hasherLabelUpper, outputPath, err)
+					continue
+				}
+				buildSumHex := hex.EncodeToString(hasher.Sum(nil))
+				hasher.Reset()
+				if err := os.WriteFile(outputShaSum, []byte(buildSumHex), os.ModePerm); err != nil {
+					colors.ErrLog("Cannot write %s sum file in `%s`: %v! Failed to generate SHA-256 sum", hasherLabelUpper, outputShaSum, err)
+					continue
+				}
+				if key != nil {
+					if err := g.cache.storeChecksum(key.(string), outputShaSum, "."+hasherLabel); err != nil {
+						colors.WarnLog("Cannot store %s sum in build cache: %v", hasherLabelUpper, err)
+					}
+				}
+				colors.Success("Generated "+colors.Green+"%s"+colors.Reset+" app "+colors.Blue+"`%s`"+colors.Reset+" for architecture "+colors.Green+"%s"+colors.Reset+" in "+colors.Yellow+"%.1f"+colors.Reset+" seconds, output: %s", buildSumHex, project.AppName, target, time.Since(tn).Seconds(), outputPath)
+			}
 		}
 	}
 	return nil
 }
 
+// checksumSuffixes returns file name suffixes of checksums generated by hash stage
+func (g *GoBuilder) checksumSuffixes() []string {
+	var suffixes []string
+	for hasherLabel := range g.hashers {
+		suffixes = append(suffixes, "."+hasherLabel)
+	}
+	return suffixes
+}
+
 func persistLog(prefix string, buf bytes.Buffer, buf2 bytes.Buffer, dir string, name string) {
 	outFileLog := filepath.Join(dir, fmt.Sprintf("%sbuild-%s.log", prefix, name))
 	outErrLog := filepath.Join(dir, fmt.Sprintf("%serror-%s.log", prefix, name))
@@ -181,6 +292,10 @@ func persistLog(prefix string, buf bytes.Buffer, buf2 bytes.Buffer, dir string,
 		outFileLog: &buf,
 		outErrLog:  &buf2,
 	} {
+		// Commands often report everything on stdout, empty error log is not worth a file
+		if k == outErrLog && v.Len() == 0 {
+			continue
+		}
 		if err := os.WriteFile(k, v.Bytes(), os.ModePerm); err != nil {
 			fmt.Printf("Error writing log: %v", err)
 			continue
@@ -192,23 +307,37 @@ func persistLog(prefix string, buf bytes.Buffer, buf2 bytes.Buffer, dir string,
 func (g *GoBuilder) buildExec(project models.Project) error {
 	for _, target := range g.targets {
 		tn := time.Now()
-		colors.Icon(colors.Yellow, "\u226b", "Building app "+colors.Blue+"%s"+colors.Green+" (%s:%s)"+colors.Reset+" to %s", project.AppName+target.EXECSUFFIX, target.GOOS, target.GOARCH, project.BuildDir)
-		outputName := fmt.Sprintf("%s-%s-%s%s", project.AppName, target.GOOS, target.GOARCH, target.EXECSUFFIX)
-		outputPath := filepath.Join(project.BuildDir, outputName)
+		colors.Icon(colors.Yellow, "\u226b", "Building app "+colors.Blue+"%s"+colors.Green+" (%s)"+colors.Reset+" to %s", project.AppName+target.EXECSUFFIX, target, project.BuildDir)
+		outputPath := filepath.Join(project.BuildDir, target.OutputName(project.AppName))
 
-		buildArgs := []string{
-			"build", "-o", outputPath,
+		flags, err := g.buildFlags(project, target)
+		if err != nil {
+			return err
 		}
-		if g.currentRelease != "" {
-			buildArgs = append(buildArgs, "--ldflags", fmt.Sprintf("-X main.releaseVersion=%s", g.currentRelease))
+		env := g.buildEnv(target)
+
+		key := ""
+		if g.cache != nil {
+			var err error
+			if key, err = g.inputHash(project, target, flags, env); err != nil {
+				colors.WarnLog("Build cache not used for app %s (%s): %v", project.AppName, target, err)
+			} else if hit, err := g.cache.restore(key, outputPath, g.checksumSuffixes()); err != nil {
+				colors.WarnLog("Cannot restore app %s (%s) from build cache: %v", project.AppName, target, err)
+			} else if hit {
+				g.cacheHits.Store(outputPath, true)
+				g.cacheKeys.Store(outputPath, key)
+				g.summary.add(project.AppName, "build (%s): cache hit", target)
+				g.report.addArtifact(project.AppName, "binary", target, outputPath, true)
+				colors.Success("Restored app "+colors.Blue+"`%s`"+colors.Reset+" for architecture "+colors.Green+"%s"+colors.Reset+" from build cache (%s), output: %s", project.AppName, target, key[:12], outputPath)
+				continue
+			}
 		}
-		buildArgs = append(buildArgs, project.AppMainSrcDir)
 
-		// Prepare the build command: go build -o outputPath project.AppMainSrcDir
-		cmd := exec.Command(filepath.Join(g.goRootPath, "bin", "go"), buildArgs...)
+		// Prepare the build command: go build -o outputPath [flags] project.AppMainSrcDir
+		buildArgs := append([]string{"build", "-o", outputPath}, flags...)
+		buildArgs = append(buildArgs, project.AppMainSrcDir)
+		cmd := exec.Command(g.toolchain.GoExec(), buildArgs...)
 		cmd.Dir = project.RootDir
-		env := append(g.defaultEnv, fmt.Sprintf("GOOS=%s", target.GOOS))
-		env = append(env, fmt.Sprintf("GOARCH=%s", target.GOARCH))
 		cmd.Env = env
 
 		// Capture output
@@ -219,9 +348,17 @@ func (g *GoBuilder) buildExec(project models.Project) error {
 		// Execute the command
 		if err := cmd.Run(); err != nil {
 			// If there's an error, return the captured stdout and stderr as part of the error
-			return fmt.Errorf("error building for %s/%s: %v\n%s\n%s", target.GOOS, target.GOARCH, err, outBuf.String(), errBuf.String())
+			return fmt.Errorf("error building for %s: %v\n%s\n%s", target, err, outBuf.String(), errBuf.String())
 		}
-		colors.Success("Successfully built app "+colors.Blue+"`%s`"+colors.Reset+" for architecture "+colors.Green+"%s:%s"+colors.Reset+" in "+colors.Yellow+"%.1f"+colors.Reset+" seconds, output: %s", project.AppName, target.GOOS, target.GOARCH, time.Since(tn).Seconds(), outputPath)
+		if key != "" {
+			if err := g.cache.store(key, outputPath); err != nil {
+				colors.WarnLog("Cannot store app %s (%s) in build cache: %v", project.AppName, target, err)
+			} else {
+				g.cacheKeys.Store(outputPath, key)
+			}
+		}
+		g.report.addArtifact(project.AppName, "binary", target, outputPath, false)
+		colors.Success("Successfully built app "+colors.Blue+"`%s`"+colors.Reset+" for architecture "+colors.Green+"%s"+colors.Reset+" in "+colors.Yellow+"%.1f"+colors.Reset+" seconds, output: %s", project.AppName, target, time.Since(tn).Seconds(), outputPath)
 	}
 
 	return nil
@@ -247,36 +384,36 @@ func loadEnvironmentFile() []string {
 	return strings.Split(string(contents), "\n")
 }
 
-func NewGoBuilder(toolchainPath string, conf models.SelectedConfig) *GoBuilder {
-	targets := GoBuilderTargets{}
-
-	for osName, osArch := range conf.Profile.OS {
-		suffix := ""
-		if osName == "windows" {
-			suffix = ".exe"
-		}
-		for _, archTarget := range osArch {
-			targets = append(targets, GoBuilderTarget{
-				GOOS:       osName,
-				GOARCH:     archTarget,
-				EXECSUFFIX: suffix,
-			})
+// NewGoBuilder creates builder using primary toolchain for building artifacts and testToolchains for the test stage.
+// Returns error when profile targets are not valid for the primary toolchain or verify-repro runs without reproducible builds
+func NewGoBuilder(toolchain models.GoToolchain, testToolchains []models.GoToolchain, conf models.SelectedConfig) (*GoBuilder, error) {
+	targets, err := newTargets(conf.Profile, toolchain)
+	if err != nil {
+		return nil, err
+	}
+	if conf.Profile.Version != "" {
+		for i := range targets {
+			targets[i].Version = conf.CurrentVersion
 		}
-
 	}
+	buildDate := time.Now()
 
 	env := os.Environ()
 	env = append(env, loadEnvironmentFile()...)
+	env = append(env, conf.GoNetworkEnv()...)
+	env = append(env, goCacheEnv(toolchain, conf)...)
 
 	stagesMap := map[string]bool{}
 	for _, stage := range conf.Profile.Stages {
 		stagesMap[stage] = true
 	}
+	// Builds stamped with VCS state, paths and the environment of the run differ every time
+	if stagesMap["verify-repro"] && !conf.Profile.Reproducible {
+		return nil, fmt.Errorf("stage verify-repro of profile %s requires `reproducible: true`", conf.ProfileName)
+	}
 
 	return &GoBuilder{
-		toolchainPath,
-		filepath.Join(toolchainPath, "go"),
-		filepath.Join(toolchainPath, "gopath"),
+		toolchain,
 		targets,
 		env,
 		map[string]hash.Hash{
@@ -286,5 +423,31 @@ func NewGoBuilder(toolchainPath string, conf models.SelectedConfig) *GoBuilder {
 		},
 		stagesMap,
 		conf.CurrentVersion,
-	}
+		env,
+		testToolchains,
+		gopkginstaller.ConfiguredTools(conf),
+		conf.Profile,
+		newRunSummary(),
+		conf.Fix,
+		sync.Map{},
+		conf.Offline,
+		&findings.Collector{},
+		&testHistory{},
+		conf.ChangedSince,
+		sync.Map{},
+		newBuildCache(conf),
+		sync.Map{},
+		sync.Map{},
+		conf.ProfileName,
+		buildDate,
+		sync.Map{},
+		sync.Map{},
+		&buildReport{
+			Version:   conf.CurrentVersion,
+			Profile:   conf.ProfileName,
+			GoVersion: toolchain.Version,
+			BuildDate: buildDate.UTC().Format(time.RFC3339),
+		},
+		sync.Map{},
+	}, nil
 }
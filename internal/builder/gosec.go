@@ -0,0 +1,194 @@
+package builder
+
+import (
+	"autobuild-go/internal/colors"
+	"autobuild-go/internal/findings"
+	"autobuild-go/internal/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gosecReport is the subset of gosec JSON output used by the builder
+type gosecReport struct {
+	Issues []struct {
+		Severity   string `json:"severity"`
+		Confidence string `json:"confidence"`
+		RuleID     string `json:"rule_id"`
+		Details    string `json:"details"`
+		File       string `json:"file"`
+		Code       string `json:"code"`
+		Line       string `json:"line"`
+		Column     string `json:"column"`
+	} `json:"Issues"`
+}
+
+// gosecCodeLineNumber matches line number prefix gosec adds to every line of code snippet
+var gosecCodeLineNumber = regexp.MustCompile(`(?m)^\d+:\s?`)
+
+// gosecExec checks module of the app with gosec. The check covers all packages, so it runs once per module
+func (g *GoBuilder) gosecExec(project models.Project) error {
+	return g.onceForModule("gosec", project, func() error {
+		return g.gosecModule(project)
+	})
+}
+
+func (g *GoBuilder) gosecModule(project models.Project) error {
+	tn := time.Now()
+	colors.Icon(colors.Yellow, "\u226b", "Go security check of module "+colors.Blue+"%s"+colors.Reset, project.RootDir)
+
+	settings := g.profile.Gosec
+	reportPath := filepath.Join(project.BuildDir, fmt.Sprintf("gosec-module-%s.json", moduleFileName(project)))
+	sarifPath := filepath.Join(project.BuildDir, fmt.Sprintf("gosec-module-%s.sarif", moduleFileName(project)))
+
+	// Findings are evaluated after baseline is applied, so gosec itself should not fail on them
+	args := []string{"-fmt=json", "-out=" + reportPath, "-no-fail", "-quiet"}
+	if settings.Severity != "" {
+		args = append(args, "-severity="+settings.Severity)
+	}
+	if settings.Confidence != "" {
+		args = append(args, "-confidence="+settings.Confidence)
+	}
+	if len(settings.ExcludeRules) > 0 {
+		args = append(args, "-exclude="+strings.Join(settings.ExcludeRules, ","))
+	}
+	for _, dir := range settings.ExcludeDirs {
+		args = append(args, "-exclude-dir="+dir)
+	}
+	if settings.ConfigFile != "" {
+		args = append(args, "-conf="+moduleRelative(project, settings.ConfigFile))
+	}
+	args = append(args, "./...")
+
+	// Prepare the security check command: gosec ./...
+	gosecExec, gosecArgs := g.toolCommand(project, "gosec")
+	cmd := exec.Command(gosecExec, append(gosecArgs, args...)...)
+	cmd.Dir = project.RootDir
+	cmd.Env = g.defaultEnv
+
+	// Capture output
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+
+	// Execute the command
+	if err := cmd.Run(); err != nil {
+		// If there's an error, return the captured stdout and stderr as part of the error
+		persistLog("gosec-", outBuf, errBuf, project.BuildDir, "module-"+moduleFileName(project))
+		return fmt.Errorf("security error in module %s: %v. Logs created", project.RootDir, err)
+	}
+
+	all, err := readGosecReport(reportPath, project.RootDir)
+	if err != nil {
+		return fmt.Errorf("cannot read gosec report of module %s: %v", project.RootDir, err)
+	}
+
+	var suppressed []findings.Finding
+	if settings.Baseline != "" {
+		known, err := readGosecReport(moduleRelative(project, settings.Baseline), project.RootDir)
+		if err != nil {
+			return fmt.Errorf("cannot read gosec baseline of module %s: %v", project.RootDir, err)
+		}
+		for i := range known {
+			known[i].File = baselineFile(project.RootDir, known[i].File)
+		}
+		all, suppressed = findings.NewBaseline(known).Filter(all)
+	}
+
+	g.findings.Add("gosec", relativeToBuildRoot(project, all))
+	if err := findings.WriteSARIF(sarifPath, []string{"gosec"}, all); err != nil {
+		colors.ErrLog("Cannot write SARIF report `%s`: %v", sarifPath, err)
+	}
+
+	summary := fmt.Sprintf("gosec: %d finding(s)", len(all))
+	if len(all) > 0 {
+		summary += " (" + findings.CountBySeverity(all) + ")"
+	}
+	if len(suppressed) > 0 {
+		summary += fmt.Sprintf(", %d suppressed by baseline", len(suppressed))
+	}
+	g.summary.add(moduleSummary(project), "%s", summary)
+
+	if len(all) > 0 {
+		for _, finding := range all {
+			colors.ErrLog("%s %s:%d [%s] %s", finding.Rule, finding.File, finding.Line, finding.Severity, finding.Message)
+		}
+		return fmt.Errorf("security error in module %s: %s. Reports stored in %s and %s", project.RootDir, summary, reportPath, sarifPath)
+	}
+	colors.Success("Successfully checked module "+colors.Blue+"`%s`"+colors.Reset+" in "+colors.Yellow+"%.1f"+colors.Reset+" seconds, %s", project.RootDir, time.Since(tn).Seconds(), summary)
+
+	return nil
+}
+
+// readGosecReport reads gosec JSON report into findings with paths relative to module root
+func readGosecReport(path string, rootDir string) ([]findings.Finding, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var report gosecReport
+	if err := json.Unmarshal(contents, &report); err != nil {
+		return nil, err
+	}
+
+	var all []findings.Finding
+	for _, issue := range report.Issues {
+		// Line may be a range, e.g. "12-14"
+		line, _ := strconv.Atoi(strings.SplitN(issue.Line, "-", 2)[0])
+		column, _ := strconv.Atoi(issue.Column)
+		all = append(all, findings.Finding{
+			Tool:       "gosec",
+			Rule:       issue.RuleID,
+			Severity:   strings.ToLower(issue.Severity),
+			Confidence: strings.ToLower(issue.Confidence),
+			File:       relativeToModule(rootDir, issue.File),
+			Line:       line,
+			Column:     column,
+			Message:    issue.Details,
+			Code:       gosecCodeLineNumber.ReplaceAllString(issue.Code, ""),
+		})
+	}
+	return all, nil
+}
+
+// moduleRelative resolves path relative to module root of project
+func moduleRelative(project models.Project, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(project.RootDir, path)
+}
+
+// baselineFile maps absolute path of baseline produced in another checkout to path relative to module root.
+// The longest suffix of the path naming an existing module file is used, e.g. /ci/agent-2/repo/cmd/app/main.go
+// becomes cmd/app/main.go
+func baselineFile(rootDir string, file string) string {
+	// Slash separated, with a drive letter when produced on windows
+	if !strings.HasPrefix(file, "/") && !(len(file) > 2 && file[1] == ':' && file[2] == '/') {
+		return file
+	}
+	parts := strings.Split(file, "/")
+	for i := 1; i < len(parts); i++ {
+		rel := strings.Join(parts[i:], "/")
+		if info, err := os.Stat(filepath.Join(rootDir, filepath.FromSlash(rel))); err == nil && !info.IsDir() {
+			return rel
+		}
+	}
+	return file
+}
+
+// relativeToModule returns slash separated path of file relative to module root
+func relativeToModule(rootDir string, file string) string {
+	if rel, err := filepath.Rel(rootDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(file)
+}
@@ -0,0 +1,243 @@
+package builder
+
+import (
+	"autobuild-go/internal/colors"
+	"autobuild-go/internal/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// fullRunFiles are files which change affects every package, any of them changed triggers full test run
+var fullRunFiles = map[string]bool{
+	"go.mod":         true,
+	"go.sum":         true,
+	"go.work":        true,
+	"go.work.sum":    true,
+	"autobuild.yaml": true,
+}
+
+// listedPackage is a part of `go list -json` output used by test impact analysis
+type listedPackage struct {
+	ImportPath     string
+	Dir            string
+	ForTest        string
+	EmbedFiles     []string // Relative to Dir, may be in subdirectories
+	IgnoredGoFiles []string
+	Deps           []string
+	Module         *struct {
+		Main bool
+	}
+}
+
+// testImpact is result of test impact analysis of a module, computed once and shared by all apps of the module
+type testImpact struct {
+	once     sync.Once
+	full     bool     // All packages have to be tested
+	packages []string // Affected packages of the module
+	reason   string
+}
+
+// affectedPackages returns packages of the project module to test when running with --changed-since,
+// full is true when all packages have to be tested
+func (g *GoBuilder) affectedPackages(project models.Project) (packages []string, full bool) {
+	value, _ := g.testImpacts.LoadOrStore(project.RootDir, &testImpact{})
+	impact := value.(*testImpact)
+	impact.once.Do(func() {
+		g.analyzeImpact(project, impact)
+		switch {
+		case impact.full:
+			colors.InfoLog("Testing all packages of module "+colors.Blue+"%s"+colors.Reset+": %s", project.RootDir, impact.reason)
+		case len(impact.packages) == 0:
+			colors.InfoLog("No packages of module "+colors.Blue+"%s"+colors.Reset+" affected by changes since %s", project.RootDir, g.changedSince)
+		default:
+			colors.InfoLog("Packages of module "+colors.Blue+"%s"+colors.Reset+" affected by changes since %s: %s", project.RootDir, g.changedSince, strings.Join(impact.packages, ", "))
+		}
+	})
+	return impact.packages, impact.full
+}
+
+func (g *GoBuilder) analyzeImpact(project models.Project, impact *testImpact) {
+	fallback := func(reason string, args ...interface{}) {
+		impact.full = true
+		impact.reason = fmt.Sprintf(reason, args...)
+	}
+
+	changed, err := changedFiles(project.RootDir, g.changedSince)
+	if err != nil {
+		fallback("cannot diff against %s: %v", g.changedSince, err)
+		return
+	}
+	for _, file := range changed {
+		if fullRunFiles[filepath.Base(file)] {
+			fallback("%s changed", file)
+			return
+		}
+	}
+
+	// Test variants (-test) carry dependencies of test files
+	cmd := exec.Command(g.toolchain.GoExec(), "list", "-e", "-deps", "-test", "-json", "./...")
+	cmd.Dir = project.RootDir
+	cmd.Env = g.defaultEnv
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	if err := cmd.Run(); err != nil {
+		fallback("go list failed: %v: %s", err, strings.TrimSpace(errBuf.String()))
+		return
+	}
+	var listed []listedPackage
+	decoder := json.NewDecoder(&outBuf)
+	for {
+		var pkg listedPackage
+		if err := decoder.Decode(&pkg); err == io.EOF {
+			break
+		} else if err != nil {
+			fallback("cannot parse go list output: %v", err)
+			return
+		}
+		listed = append(listed, pkg)
+	}
+
+	// Packages (also dependencies from other local modules) with changed files in their directory or embedded.
+	// Paths of both sides are resolved, git reports them without symlinks while go list keeps them
+	dirPackages := map[string][]string{}
+	filePackages := map[string][]string{}
+	for _, pkg := range listed {
+		if pkg.Dir == "" {
+			continue
+		}
+		dir := resolvePath(pkg.Dir)
+		dirPackages[dir] = append(dirPackages[dir], packagePath(pkg.ImportPath))
+		for _, file := range append(append([]string{}, pkg.EmbedFiles...), pkg.IgnoredGoFiles...) {
+			file = filepath.Join(dir, filepath.FromSlash(file))
+			filePackages[file] = append(filePackages[file], packagePath(pkg.ImportPath))
+		}
+	}
+	rootDir := resolvePath(project.RootDir)
+	buildDir := resolvePath(project.BuildDir) + string(filepath.Separator)
+	changedPackages := map[string]bool{}
+	for _, file := range changed {
+		if file = resolvePath(file); strings.HasPrefix(file, buildDir) {
+			continue
+		}
+		owners := append(append([]string{}, filePackages[file]...), dirPackages[filepath.Dir(file)]...)
+		for dir, pkgs := range dirPackages {
+			if strings.HasPrefix(file, filepath.Join(dir, "testdata")+string(filepath.Separator)) {
+				owners = append(owners, pkgs...)
+			}
+		}
+		if len(owners) == 0 && moduleDir(filepath.Dir(file)) == rootDir {
+			// Unknown file of the module, e.g. embedded by a package which failed to load
+			fallback("%s belongs to no package", file)
+			return
+		}
+		for _, pkg := range owners {
+			changedPackages[pkg] = true
+		}
+	}
+
+	affected := map[string]bool{}
+	for _, pkg := range listed {
+		if pkg.Module == nil || !pkg.Module.Main || strings.HasSuffix(pkg.ImportPath, ".test") {
+			continue
+		}
+		tested := packagePath(pkg.ImportPath)
+		if pkg.ForTest != "" {
+			tested = pkg.ForTest
+		}
+		if changedPackages[packagePath(pkg.ImportPath)] {
+			affected[tested] = true
+			continue
+		}
+		for _, dep := range pkg.Deps {
+			if changedPackages[packagePath(dep)] {
+				affected[tested] = true
+				break
+			}
+		}
+	}
+
+	for pkg := range affected {
+		impact.packages = append(impact.packages, pkg)
+	}
+	sort.Strings(impact.packages)
+}
+
+// packagePath strips test variant suffix, e.g. `example.com/app [example.com/app.test]`
+func packagePath(importPath string) string {
+	if i := strings.Index(importPath, " ["); i >= 0 {
+		return importPath[:i]
+	}
+	return importPath
+}
+
+// resolvePath returns path with symlinks resolved, also of removed files by resolving the nearest existing parent
+func resolvePath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path
+	}
+	return filepath.Join(resolvePath(parent), filepath.Base(path))
+}
+
+// moduleDir returns directory of the nearest go.mod above dir, empty when there is none
+func moduleDir(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// changedFiles returns absolute paths of files changed since ref in git repository containing dir,
+// worktree changes and untracked files included
+func changedFiles(dir string, ref string) ([]string, error) {
+	git := func(args ...string) (string, error) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		var outBuf, errBuf bytes.Buffer
+		cmd.Stdout = &outBuf
+		cmd.Stderr = &errBuf
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(errBuf.String()))
+		}
+		return outBuf.String(), nil
+	}
+
+	topLevel, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	diff, err := git("diff", "--name-only", "--no-renames", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git("ls-files", "--others", "--exclude-standard", "--full-name", "--", ":/")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, file := range strings.Split(diff+"\n"+untracked, "\n") {
+		if file = strings.TrimSpace(file); file != "" {
+			files = append(files, filepath.Join(strings.TrimSpace(topLevel), filepath.FromSlash(file)))
+		}
+	}
+	return files, nil
+}
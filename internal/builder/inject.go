@@ -0,0 +1,210 @@
+package builder
+
+import (
+	"autobuild-go/internal/colors"
+	"autobuild-go/internal/models"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// buildInfo are values available in `inject` templates, e.g. `{{.Version}}-{{.ShortCommit}}`
+type buildInfo struct {
+	Version     string // Release version
+	Commit      string // Full git commit hash
+	ShortCommit string // Abbreviated git commit hash
+	Dirty       bool   // Worktree has uncommitted changes
+	CommitDate  string // Commit date, RFC 3339
+	BuildDate   string // Build start date, RFC 3339. Commit date or SOURCE_DATE_EPOCH in reproducible builds
+	Host        string // Host name of the builder, empty in reproducible builds
+	GoVersion   string // Go version used to build
+	Profile     string // Selected profile
+	Target      string // Target description, e.g. `linux:amd64`
+	OS          string
+	Arch        string
+	Variant     string // Build variant name
+}
+
+// gitInfo is git state of a module, read once per module root
+type gitInfo struct {
+	once        sync.Once
+	commit      string
+	shortCommit string
+	dirty       bool
+	modified    bool // Also with untracked files, like vcs.modified stamped by go build
+	commitDate  string
+}
+
+// moduleGitInfo returns git state of the project module, empty values outside of git repositories
+func (g *GoBuilder) moduleGitInfo(project models.Project) *gitInfo {
+	value, _ := g.gitInfos.LoadOrStore(project.RootDir, &gitInfo{})
+	info := value.(*gitInfo)
+	info.once.Do(func() {
+		git := func(args ...string) string {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = project.RootDir
+			output, err := cmd.Output()
+			if err != nil {
+				return ""
+			}
+			return strings.TrimSpace(string(output))
+		}
+		info.commit = git("rev-parse", "HEAD")
+		info.shortCommit = git("rev-parse", "--short", "HEAD")
+		info.commitDate = git("log", "-1", "--format=%cI")
+		info.dirty = info.commit != "" && git("status", "--porcelain", "--untracked-files=no") != ""
+		info.modified = info.commit != "" && git("status", "--porcelain") != ""
+	})
+	return info
+}
+
+// injectFlags returns `-X` linker flags of variables configured in profile `inject` section
+func (g *GoBuilder) injectFlags(project models.Project, target GoBuilderTarget) ([]string, error) {
+	if len(g.profile.Inject) == 0 {
+		return nil, nil
+	}
+
+	git := g.moduleGitInfo(project)
+	host := ""
+	if !g.profile.Reproducible {
+		host, _ = os.Hostname()
+	}
+	info := buildInfo{
+		Version:     g.currentRelease,
+		Commit:      git.commit,
+		ShortCommit: git.shortCommit,
+		Dirty:       git.dirty,
+		CommitDate:  git.commitDate,
+		BuildDate:   g.buildTime(project).UTC().Format("2006-01-02T15:04:05Z07:00"),
+		Host:        host,
+		GoVersion:   g.toolchain.Version,
+		Profile:     g.profileName,
+		Target:      target.String(),
+		OS:          target.GOOS,
+		Arch:        target.Arch(),
+		Variant:     target.BuildVariant,
+	}
+
+	var variables []string
+	for variable := range g.profile.Inject {
+		variables = append(variables, variable)
+	}
+	sort.Strings(variables)
+
+	var flags []string
+	for _, variable := range variables {
+		tmpl, err := template.New(variable).Option("missingkey=error").Parse(g.profile.Inject[variable])
+		if err != nil {
+			return nil, fmt.Errorf("invalid inject template of %s: %v", variable, err)
+		}
+		var value bytes.Buffer
+		if err := tmpl.Execute(&value, info); err != nil {
+			return nil, fmt.Errorf("invalid inject template of %s: %v", variable, err)
+		}
+		g.checkInjected(project, variable)
+		flag, err := quoteFlag(variable + "=" + value.String())
+		if err != nil {
+			return nil, fmt.Errorf("invalid injected value of %s: %v", variable, err)
+		}
+		flags = append(flags, "-X", flag)
+	}
+	return flags, nil
+}
+
+// quoteFlag quotes value containing spaces for `-ldflags`, which is split on spaces into fields optionally
+// quoted with ' or ". There are no escapes, so a value cannot contain both quote characters
+func quoteFlag(value string) (string, error) {
+	switch {
+	case !strings.ContainsAny(value, " \t\n\r'\""):
+		return value, nil
+	case !strings.Contains(value, "'"):
+		return "'" + value + "'", nil
+	case !strings.Contains(value, `"`):
+		return `"` + value + `"`, nil
+	}
+	return "", fmt.Errorf("`%s` contains both ' and \", which -ldflags cannot pass", value)
+}
+
+// checkInjected warns once per project and variable when the package does not declare the string variable,
+// in which case the linker silently ignores `-X`
+func (g *GoBuilder) checkInjected(project models.Project, variable string) {
+	if _, checked := g.injectChecks.LoadOrStore(project.AppMainSrcDir+"|"+variable, true); checked {
+		return
+	}
+
+	dot := strings.LastIndex(variable, ".")
+	if dot <= 0 {
+		colors.WarnLog("Injected variable `%s` has to be `<package path>.<name>`", variable)
+		return
+	}
+	pkgPath, name := variable[:dot], variable[dot+1:]
+
+	dir := project.AppMainSrcDir
+	if pkgPath != "main" {
+		cmd := exec.Command(g.toolchain.GoExec(), "list", "-find", "-f", "{{.Dir}}", pkgPath)
+		cmd.Dir = project.RootDir
+		cmd.Env = g.defaultEnv
+		output, err := cmd.Output()
+		if err != nil {
+			colors.WarnLog("Package of injected variable `%s` not found in app %s", variable, project.AppName)
+			return
+		}
+		dir = strings.TrimSpace(string(output))
+	}
+
+	switch declared, isString := declaresStringVar(dir, name); {
+	case !declared:
+		colors.WarnLog("Variable `%s` not declared, value injected to app %s is ignored", variable, project.AppName)
+	case !isString:
+		colors.WarnLog("Variable `%s` is not an uninitialized or constant string, value injected to app %s is ignored", variable, project.AppName)
+	}
+}
+
+// declaresStringVar scans package in dir for package level variable name and reports whether it can be set with `-X`
+func declaresStringVar(dir string, name string) (declared bool, isString bool) {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		parsed, err := parser.ParseFile(fset, file, nil, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+		for _, decl := range parsed.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				valueSpec := spec.(*ast.ValueSpec)
+				for i, ident := range valueSpec.Names {
+					if ident.Name != name {
+						continue
+					}
+					// Linker can only replace strings which are not initialized at run time
+					constant := true
+					if i < len(valueSpec.Values) {
+						lit, ok := valueSpec.Values[i].(*ast.BasicLit)
+						constant = ok && lit.Kind == token.STRING
+					}
+					if typ, ok := valueSpec.Type.(*ast.Ident); ok {
+						return true, typ.Name == "string" && constant
+					}
+					return true, valueSpec.Type == nil && i < len(valueSpec.Values) && constant
+				}
+			}
+		}
+	}
+	return false, false
+}
@@ -0,0 +1,268 @@
+package builder
+
+import (
+	"autobuild-go/internal/colors"
+	"autobuild-go/internal/findings"
+	"autobuild-go/internal/models"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// staticcheckIssue is a single line of `staticcheck -f json` output
+type staticcheckIssue struct {
+	Code     string `json:"code"`
+	Severity string `json:"severity"`
+	Location struct {
+		File   string `json:"file"`
+		Line   int    `json:"line"`
+		Column int    `json:"column"`
+	} `json:"location"`
+	Message string `json:"message"`
+}
+
+// golangciReport is the subset of `golangci-lint run --out-format json` output used by the builder
+type golangciReport struct {
+	Issues []struct {
+		FromLinter  string   `json:"FromLinter"`
+		Text        string   `json:"Text"`
+		Severity    string   `json:"Severity"`
+		SourceLines []string `json:"SourceLines"`
+		Pos         struct {
+			Filename string `json:"Filename"`
+			Line     int    `json:"Line"`
+			Column   int    `json:"Column"`
+		} `json:"Pos"`
+	} `json:"Issues"`
+}
+
+// lintExec runs configured linter once per module and fails on (new) findings
+func (g *GoBuilder) lintExec(project models.Project) error {
+	return g.onceForModule("lint", project, func() error {
+		tn := time.Now()
+		settings := g.profile.Lint
+		linter := settings.LinterName()
+		colors.Icon(colors.Yellow, "\u226b", "Linting module "+colors.Blue+"%s"+colors.Reset+" with %s", project.RootDir, linter)
+
+		var args []string
+		switch linter {
+		case "staticcheck":
+			args = []string{"-f", "json"}
+		case "golangci-lint":
+			args = []string{"run", "--out-format", "json", "--issues-exit-code", "0"}
+		default:
+			return fmt.Errorf("unsupported linter `%s`, use staticcheck or golangci-lint", linter)
+		}
+		args = append(append(args, settings.Args...), "./...")
+
+		lintExec, toolArgs := g.toolCommand(project, linter)
+		cmd := exec.Command(lintExec, append(toolArgs, args...)...)
+		cmd.Dir = project.RootDir
+		cmd.Env = g.defaultEnv
+
+		var outBuf, errBuf bytes.Buffer
+		cmd.Stdout = &outBuf
+		cmd.Stderr = &errBuf
+		runErr := cmd.Run()
+
+		all, parseErr := parseLinterOutput(linter, outBuf.Bytes(), project.RootDir)
+		// staticcheck exits with 1 when it reports issues, so exit code alone does not mean failure
+		if parseErr != nil || (runErr != nil && len(all) == 0) {
+			persistLog("lint-", outBuf, errBuf, project.BuildDir, filepath.Base(project.RootDir))
+			return fmt.Errorf("%s failed in %s: %v %v. Logs created", linter, project.RootDir, runErr, parseErr)
+		}
+
+		reported := all
+		if settings.NewFrom != "" {
+			changed, err := changedLines(project.RootDir, settings.NewFrom)
+			if err != nil {
+				return fmt.Errorf("cannot compute changes since `%s` in %s: %v", settings.NewFrom, project.RootDir, err)
+			}
+			reported = changed.filter(all)
+		}
+
+		g.findings.Add(linter, relativeToBuildRoot(project, reported))
+		sarifPath := filepath.Join(project.BuildDir, fmt.Sprintf("lint-%s.sarif", filepath.Base(project.RootDir)))
+		if err := findings.WriteSARIF(sarifPath, []string{linter}, reported); err != nil {
+			colors.ErrLog("Cannot write SARIF report `%s`: %v", sarifPath, err)
+		}
+
+		summary := fmt.Sprintf("lint: %d finding(s)", len(reported))
+		if settings.NewFrom != "" {
+			summary += fmt.Sprintf(" new since %s, %d in total", settings.NewFrom, len(all))
+		}
+		g.summary.add(moduleSummary(project), "%s", summary)
+
+		if len(reported) > 0 {
+			for _, finding := range reported {
+				colors.ErrLog("%s:%d:%d: %s (%s)", finding.File, finding.Line, finding.Column, finding.Message, finding.Rule)
+			}
+			return fmt.Errorf("%s: %s. Report stored in %s", project.RootDir, summary, sarifPath)
+		}
+		colors.Success("Successfully linted module "+colors.Blue+"`%s`"+colors.Reset+" in "+colors.Yellow+"%.1f"+colors.Reset+" seconds, %s", project.RootDir, time.Since(tn).Seconds(), summary)
+		return nil
+	})
+}
+
+// parseLinterOutput normalises linter JSON output into findings with paths relative to module root
+func parseLinterOutput(linter string, output []byte, rootDir string) ([]findings.Finding, error) {
+	var all []findings.Finding
+	switch linter {
+	case "staticcheck":
+		scanner := bufio.NewScanner(bytes.NewReader(output))
+		scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+		for scanner.Scan() {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			var issue staticcheckIssue
+			if err := json.Unmarshal(scanner.Bytes(), &issue); err != nil {
+				return nil, err
+			}
+			all = append(all, findings.Finding{
+				Tool:     linter,
+				Rule:     issue.Code,
+				Severity: lintSeverity(issue.Severity),
+				File:     relativeToModule(rootDir, issue.Location.File),
+				Line:     issue.Location.Line,
+				Column:   issue.Location.Column,
+				Message:  issue.Message,
+			})
+		}
+		return all, scanner.Err()
+	case "golangci-lint":
+		var report golangciReport
+		if err := json.Unmarshal(output, &report); err != nil {
+			return nil, err
+		}
+		for _, issue := range report.Issues {
+			file := issue.Pos.Filename
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(rootDir, file)
+			}
+			all = append(all, findings.Finding{
+				Tool:     linter,
+				Rule:     issue.FromLinter,
+				Severity: lintSeverity(issue.Severity),
+				File:     relativeToModule(rootDir, file),
+				Line:     issue.Pos.Line,
+				Column:   issue.Pos.Column,
+				Message:  issue.Text,
+				Code:     strings.Join(issue.SourceLines, "\n"),
+			})
+		}
+		return all, nil
+	}
+	return nil, fmt.Errorf("unsupported linter `%s`", linter)
+}
+
+// lintSeverity maps linter severities to high, medium or low. Linters without severity report medium
+func lintSeverity(severity string) string {
+	switch strings.ToLower(severity) {
+	case "error", "high":
+		return "high"
+	case "info", "low", "ignored":
+		return "low"
+	}
+	return "medium"
+}
+
+// relativeToBuildRoot rewrites finding paths from module relative to relative to the scanned root (parent of .build),
+// so findings of different modules can be combined
+func relativeToBuildRoot(project models.Project, found []findings.Finding) []findings.Finding {
+	root := filepath.Dir(project.BuildDir)
+	result := make([]findings.Finding, 0, len(found))
+	for _, finding := range found {
+		finding.File = relativeToModule(root, filepath.Join(project.RootDir, filepath.FromSlash(finding.File)))
+		result = append(result, finding)
+	}
+	return result
+}
+
+// changeSet holds lines changed since merge base, keyed by module relative file. Nil line set means whole file is new
+type changeSet map[string]map[int]bool
+
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+// changedLines returns lines of module files changed since merge base of HEAD and ref, including uncommitted and untracked files
+func changedLines(rootDir string, ref string) (changeSet, error) {
+	git := func(args ...string) (string, error) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = rootDir
+		var outBuf, errBuf bytes.Buffer
+		cmd.Stdout = &outBuf
+		cmd.Stderr = &errBuf
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(errBuf.String()))
+		}
+		return outBuf.String(), nil
+	}
+
+	mergeBase, err := git("merge-base", "HEAD", ref)
+	if err != nil {
+		return nil, err
+	}
+	// Paths are relative to module root thanks to --relative
+	diff, err := git("diff", "--relative", "-U0", "--no-color", strings.TrimSpace(mergeBase), "--", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	changes := changeSet{}
+	file := ""
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			file = strings.TrimPrefix(strings.TrimPrefix(line, "+++ "), "b/")
+			if file == "/dev/null" {
+				file = ""
+			} else if changes[file] == nil {
+				changes[file] = map[int]bool{}
+			}
+		case strings.HasPrefix(line, "@@") && file != "":
+			match := hunkHeader.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			start, _ := strconv.Atoi(match[1])
+			count := 1
+			if match[2] != "" {
+				count, _ = strconv.Atoi(match[2])
+			}
+			for i := start; i < start+count; i++ {
+				changes[file][i] = true
+			}
+		}
+	}
+
+	untracked, err := git("ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range strings.Fields(untracked) {
+		changes[filepath.ToSlash(file)] = nil
+	}
+	return changes, nil
+}
+
+// filter keeps findings located on changed lines
+func (c changeSet) filter(all []findings.Finding) []findings.Finding {
+	var kept []findings.Finding
+	for _, finding := range all {
+		lines, ok := c[finding.File]
+		if !ok {
+			continue
+		}
+		if lines == nil || lines[finding.Line] {
+			kept = append(kept, finding)
+		}
+	}
+	return kept
+}
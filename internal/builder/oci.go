@@ -0,0 +1,208 @@
+package builder
+
+import (
+	"autobuild-go/internal/colors"
+	"autobuild-go/internal/models"
+	"autobuild-go/internal/ociimage"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// invalidTagChars matches characters not allowed in image tags, e.g. `+` of semantic version build metadata
+var invalidTagChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// ociExec builds multi-platform OCI image of the app from its linux binaries, one image per build variant
+func (g *GoBuilder) ociExec(project models.Project) error {
+	conf := g.profile.Image
+
+	var base *ociimage.Base
+	if conf.Base != "" && conf.Base != "scratch" {
+		var err error
+		if base, err = ociimage.LoadBase(filepath.Join(project.RootDir, filepath.FromSlash(conf.Base))); err != nil {
+			return err
+		}
+	}
+
+	// Targets grouped by build variant, in order of targets
+	var variants []string
+	groups := map[string]GoBuilderTargets{}
+	for _, target := range g.targets {
+		if target.GOOS != "linux" {
+			continue
+		}
+		if _, ok := groups[target.BuildVariant]; !ok {
+			variants = append(variants, target.BuildVariant)
+		}
+		groups[target.BuildVariant] = append(groups[target.BuildVariant], target)
+	}
+	if len(variants) == 0 {
+		colors.WarnLog("No linux targets, image of app %s not built", project.AppName)
+		return nil
+	}
+
+	for _, variant := range variants {
+		if err := g.ociImage(project, base, groups[variant]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ociImage builds image with a platform for every target, stored as OCI layout directory and `docker load` tarball
+func (g *GoBuilder) ociImage(project models.Project, base *ociimage.Base, targets GoBuilderTargets) error {
+	tn := time.Now()
+	conf := g.profile.Image
+	variant := targets[0].BuildVariant
+
+	name := conf.Name
+	if name == "" {
+		name = project.AppName
+		if variant != "" {
+			name += "-" + variant
+		}
+		name = strings.ToLower(name)
+	} else if variant != "" {
+		name += "-" + variant
+	}
+	if name != strings.ToLower(name) {
+		return fmt.Errorf("invalid image name `%s`, only lowercase letters are allowed", name)
+	}
+	version := targets[0].Version
+	if version == "" {
+		version = g.currentRelease
+	}
+	tags, err := imageTags(conf.Tags, version)
+	if err != nil {
+		return err
+	}
+
+	binDir := conf.BinDir
+	if binDir == "" {
+		binDir = "/usr/local/bin"
+	}
+	binaryPath := path.Join(binDir, project.AppName)
+	entrypoint := conf.Entrypoint
+	if len(entrypoint) == 0 {
+		entrypoint = []string{binaryPath}
+	}
+	config := ociimage.Config{
+		Entrypoint: entrypoint,
+		Cmd:        conf.Cmd,
+		Env:        conf.Env,
+		WorkingDir: conf.WorkDir,
+		User:       conf.User,
+		Ports:      conf.Ports,
+		Labels:     conf.Labels,
+	}
+
+	var extraFiles []ociimage.File
+	var imagePaths []string
+	for imagePath := range conf.Files {
+		imagePaths = append(imagePaths, imagePath)
+	}
+	sort.Strings(imagePaths)
+	for _, imagePath := range imagePaths {
+		files, err := installedFiles(project, conf.Files[imagePath], imagePath, false)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			extraFiles = append(extraFiles, ociimage.File{Path: file.Path, Source: file.Source, Mode: file.Mode})
+		}
+	}
+
+	imageName := strings.TrimSuffix(project.AppName+"-"+variant, "-") + "-oci"
+	layoutDir := filepath.Join(project.BuildDir, imageName)
+	tarPath := layoutDir + ".tar"
+	colors.Icon(colors.Yellow, "\u226b", "Building image "+colors.Blue+"%s"+colors.Reset+" of app "+colors.Blue+"%s"+colors.Reset+" to %s", name, project.AppName, layoutDir)
+
+	layout := ociimage.NewLayout(g.sourceDate(project))
+	var platforms []string
+	for _, target := range targets {
+		binary := filepath.Join(project.BuildDir, target.OutputName(project.AppName))
+		if _, err := os.Stat(binary); err != nil {
+			return fmt.Errorf("cannot build image of app %s (%s), binary not built: %v", project.AppName, target, err)
+		}
+		files := append([]ociimage.File{{Path: binaryPath, Source: binary, Mode: 0o755}}, extraFiles...)
+		platform := ociPlatform(target)
+		for i, other := range platforms {
+			if other == platform.String() {
+				return fmt.Errorf("image of app %s has platform %s for both %s and %s, build one of them only", project.AppName, other, targets[i], target)
+			}
+		}
+		if err := layout.AddImage(base, platform, files, config, "-"+target.Arch()); err != nil {
+			return fmt.Errorf("cannot build image of app %s (%s): %v", project.AppName, target, err)
+		}
+		platforms = append(platforms, platform.String())
+	}
+
+	digest, err := layout.Write(layoutDir, tarPath, name, tags)
+	if err != nil {
+		return fmt.Errorf("cannot write image of app %s: %v", project.AppName, err)
+	}
+
+	g.addPackaged(filepath.Join(project.BuildDir, targets[0].OutputName(project.AppName)), tarPath)
+	for _, target := range targets {
+		g.report.addArtifact(project.AppName, "oci", target, tarPath, false)
+	}
+	g.summary.add(project.AppName, "oci: %s:%s (%s), %s", name, strings.Join(tags, ","), strings.Join(platforms, ", "), digest[:19])
+	colors.Success("Built image "+colors.Blue+"`%s`"+colors.Reset+" for "+colors.Green+"%s"+colors.Reset+" in "+colors.Yellow+"%.1f"+colors.Reset+" seconds, output: %s and %s", name+":"+tags[0], strings.Join(platforms, ", "), time.Since(tn).Seconds(), layoutDir, tarPath)
+	return nil
+}
+
+// imageTags renders tag templates with `.Version`, which is made a valid tag first: 1.2.4-snapshot.5+abc1234 gives
+// 1.2.4-snapshot.5_abc1234. Without templates the version is the tag, `latest` when there is no version
+func imageTags(templates []string, version string) ([]string, error) {
+	version = invalidTagChars.ReplaceAllString(strings.TrimPrefix(version, "v"), "_")
+	if len(templates) == 0 {
+		if version == "" {
+			return []string{"latest"}, nil
+		}
+		return []string{version}, nil
+	}
+
+	var tags []string
+	for _, text := range templates {
+		tmpl, err := template.New("tag").Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid image tag template: %v", err)
+		}
+		var tag bytes.Buffer
+		if err := tmpl.Execute(&tag, struct{ Version string }{version}); err != nil {
+			return nil, fmt.Errorf("invalid image tag template: %v", err)
+		}
+		value := tag.String()
+		if value == "" || len(value) > 128 || invalidTagChars.MatchString(value) || strings.HasPrefix(value, ".") || strings.HasPrefix(value, "-") {
+			return nil, fmt.Errorf("invalid image tag `%s`", value)
+		}
+		tags = append(tags, value)
+	}
+	return tags, nil
+}
+
+// ociPlatform returns image platform of target, microarchitecture variants map to platform variants, e.g. linux/arm/v7
+func ociPlatform(target GoBuilderTarget) ociimage.Platform {
+	platform := ociimage.Platform{OS: target.GOOS, Architecture: target.GOARCH}
+	switch target.GOARCH {
+	case "arm":
+		platform.Variant = "v7"
+		if target.Variant != "" {
+			platform.Variant = "v" + target.Variant[:1]
+		}
+	case "arm64":
+		if target.Variant != "" {
+			platform.Variant = strings.SplitN(target.Variant, ".", 2)[0]
+		}
+	case "amd64":
+		platform.Variant = target.Variant
+	}
+	return platform
+}
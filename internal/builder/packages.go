@@ -0,0 +1,233 @@
+package builder
+
+import (
+	"autobuild-go/internal/colors"
+	"autobuild-go/internal/models"
+	"autobuild-go/internal/ospackage"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// packageFormat is a package format produced by `deb` or `rpm` stage
+type packageFormat struct {
+	name       string
+	arch       func(goarch string, variant string) (string, bool)
+	fileName   func(ospackage.Package) string
+	write      func(io.Writer, ospackage.Package) error
+	systemdDir string
+	conf       func(models.Package) models.PackageFormat
+}
+
+var packageFormats = map[string]packageFormat{
+	"deb": {
+		name:       "deb",
+		arch:       ospackage.DebArch,
+		fileName:   ospackage.DebFileName,
+		write:      ospackage.WriteDeb,
+		systemdDir: "/lib/systemd/system",
+		conf:       func(p models.Package) models.PackageFormat { return p.Deb },
+	},
+	"rpm": {
+		name:       "rpm",
+		arch:       ospackage.RPMArch,
+		fileName:   ospackage.RPMFileName,
+		write:      ospackage.WriteRPM,
+		systemdDir: "/usr/lib/systemd/system",
+		conf:       func(p models.Package) models.PackageFormat { return p.RPM },
+	},
+}
+
+// packageExec builds package of given format for every linux target of the app
+func (g *GoBuilder) packageExec(project models.Project, formatName string) error {
+	format := packageFormats[formatName]
+	// Package paths to targets packaged to them, several targets can map to one distribution architecture
+	packaged := map[string]GoBuilderTarget{}
+	for _, target := range g.targets {
+		if target.GOOS != "linux" {
+			continue
+		}
+		tn := time.Now()
+		arch, ok := format.arch(target.GOARCH, target.Variant)
+		if !ok {
+			colors.WarnLog("Architecture %s has no %s equivalent, package of app %s skipped", target.Arch(), format.name, project.AppName)
+			continue
+		}
+		outputPath := filepath.Join(project.BuildDir, target.OutputName(project.AppName))
+		if _, err := os.Stat(outputPath); err != nil {
+			return fmt.Errorf("cannot package app %s (%s), binary not built: %v", project.AppName, target, err)
+		}
+
+		pkg, err := g.packageContents(project, target, format, outputPath)
+		if err != nil {
+			return fmt.Errorf("invalid %s package of app %s: %v", format.name, project.AppName, err)
+		}
+		pkg.Arch = arch
+
+		packagePath := filepath.Join(project.BuildDir, format.fileName(pkg))
+		if other, ok := packaged[packagePath]; ok && other.BuildVariant != target.BuildVariant {
+			return fmt.Errorf("%s packages of app %s for %s and %s are both named %s", format.name, project.AppName, other, target, filepath.Base(packagePath))
+		} else if ok {
+			// Microarchitecture variants of one distribution architecture share the package file
+			colors.WarnLog("Package %s of app %s for %s already built for %s, %s package skipped", filepath.Base(packagePath), project.AppName, target, other, format.name)
+			continue
+		}
+		packaged[packagePath] = target
+		colors.Icon(colors.Yellow, "\u226b", "Packaging app "+colors.Blue+"%s"+colors.Green+" (%s)"+colors.Reset+" to %s", project.AppName, target, packagePath)
+		var buf bytes.Buffer
+		if err := format.write(&buf, pkg); err != nil {
+			return fmt.Errorf("cannot create %s package of app %s: %v", format.name, project.AppName, err)
+		}
+		if err := os.WriteFile(packagePath, buf.Bytes(), 0o644); err != nil {
+			return fmt.Errorf("cannot write package %s: %v", packagePath, err)
+		}
+
+		g.addPackaged(outputPath, packagePath)
+		g.report.addArtifact(project.AppName, format.name, target, packagePath, false)
+		g.summary.add(project.AppName, "%s (%s): %s", format.name, target, filepath.Base(packagePath))
+		colors.Success("Packaged app "+colors.Blue+"`%s`"+colors.Reset+" for architecture "+colors.Green+"%s"+colors.Reset+" in "+colors.Yellow+"%.1f"+colors.Reset+" seconds, output: %s", project.AppName, target, time.Since(tn).Seconds(), packagePath)
+	}
+	return nil
+}
+
+// packageContents collects metadata, files and scripts of the package from profile `package` section
+func (g *GoBuilder) packageContents(project models.Project, target GoBuilderTarget, format packageFormat, binaryPath string) (ospackage.Package, error) {
+	conf := g.profile.Package
+
+	name := conf.Name
+	if name == "" {
+		name = project.AppName
+		if target.BuildVariant != "" {
+			name += "-" + target.BuildVariant
+		}
+		name = strings.ToLower(name)
+	} else if target.BuildVariant != "" {
+		name += "-" + target.BuildVariant
+	}
+	version := target.Version
+	if version == "" {
+		version = g.currentRelease
+	}
+	if version == "" {
+		version = "0.0.0"
+	}
+	release := conf.Release
+	if release == "" {
+		release = "1"
+	}
+	binDir := conf.BinDir
+	if binDir == "" {
+		binDir = "/usr/bin"
+	}
+
+	pkg := ospackage.Package{
+		Name:        name,
+		Version:     version,
+		Release:     release,
+		Maintainer:  conf.Maintainer,
+		Vendor:      conf.Vendor,
+		Homepage:    conf.Homepage,
+		License:     conf.License,
+		Description: conf.Description,
+		Depends:     append(append([]string{}, conf.Depends...), format.conf(conf).Depends...),
+		Modified:    g.sourceDate(project),
+		Files:       []ospackage.File{{Path: path.Join(binDir, project.AppName), Source: binaryPath, Mode: 0o755}},
+	}
+
+	for _, group := range []struct {
+		files  map[string]string
+		config bool
+	}{
+		{conf.Files, false},
+		{conf.ConfigFiles, true},
+	} {
+		var installPaths []string
+		for installPath := range group.files {
+			installPaths = append(installPaths, installPath)
+		}
+		sort.Strings(installPaths)
+		for _, installPath := range installPaths {
+			files, err := installedFiles(project, group.files[installPath], installPath, group.config)
+			if err != nil {
+				return pkg, err
+			}
+			pkg.Files = append(pkg.Files, files...)
+		}
+	}
+	for _, unit := range conf.Systemd {
+		files, err := installedFiles(project, unit, path.Join(format.systemdDir, path.Base(filepath.ToSlash(unit))), false)
+		if err != nil {
+			return pkg, err
+		}
+		pkg.Files = append(pkg.Files, files...)
+	}
+
+	for _, script := range []struct {
+		source string
+		dest   *string
+	}{
+		{conf.Scripts.PreInstall, &pkg.Scripts.PreInstall},
+		{conf.Scripts.PostInstall, &pkg.Scripts.PostInstall},
+		{conf.Scripts.PreRemove, &pkg.Scripts.PreRemove},
+		{conf.Scripts.PostRemove, &pkg.Scripts.PostRemove},
+	} {
+		if script.source == "" {
+			continue
+		}
+		contents, err := os.ReadFile(filepath.Join(project.RootDir, filepath.FromSlash(script.source)))
+		if err != nil {
+			return pkg, fmt.Errorf("cannot read maintainer script: %v", err)
+		}
+		*script.dest = string(contents)
+	}
+	return pkg, nil
+}
+
+// installedFiles resolves source relative to module root to files installed at installPath.
+// Directories are installed with their contents
+func installedFiles(project models.Project, source string, installPath string, config bool) ([]ospackage.File, error) {
+	if !path.IsAbs(installPath) {
+		return nil, fmt.Errorf("install path `%s` of `%s` has to be absolute", installPath, source)
+	}
+	root := filepath.Join(project.RootDir, filepath.FromSlash(source))
+	var files []ospackage.File
+	err := filepath.WalkDir(root, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		// Only executable bit of source files is kept
+		mode := fs.FileMode(0o644)
+		if info.Mode()&0o111 != 0 {
+			mode = 0o755
+		}
+		files = append(files, ospackage.File{Path: path.Join(installPath, filepath.ToSlash(rel)), Source: file, Mode: mode, Config: config})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read package file `%s`: %v", source, err)
+	}
+	return files, nil
+}
+
+// addPackaged records archive or package made of binary, so hash stage checksums it too
+func (g *GoBuilder) addPackaged(binaryPath string, path string) {
+	value, _ := g.packaged.LoadOrStore(binaryPath, &[]string{})
+	// Targets of a project are processed by one goroutine
+	paths := value.(*[]string)
+	*paths = append(*paths, path)
+}
@@ -0,0 +1,67 @@
+package builder
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+)
+
+// reportArtifact is a file produced by the run
+type reportArtifact struct {
+	App     string `json:"app"`
+	Kind    string `json:"kind"` // binary, archive, package...
+	Target  string `json:"target"`
+	OS      string `json:"os"`
+	Arch    string `json:"arch"`
+	Variant string `json:"variant,omitempty"`
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	Cached  bool   `json:"cached,omitempty"` // Restored from build cache
+}
+
+// buildReport describes the run in machine readable form, stored as `build-report.json` in build directory
+type buildReport struct {
+	mutex     sync.Mutex
+	Version   string           `json:"version"`
+	Profile   string           `json:"profile"`
+	GoVersion string           `json:"go_version"`
+	BuildDate string           `json:"build_date"`
+	Artifacts []reportArtifact `json:"artifacts"`
+}
+
+// addArtifact records artifact at path built for target
+func (r *buildReport) addArtifact(app string, kind string, target GoBuilderTarget, path string, cached bool) {
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Artifacts = append(r.Artifacts, reportArtifact{
+		App:     app,
+		Kind:    kind,
+		Target:  target.String(),
+		OS:      target.GOOS,
+		Arch:    target.Arch(),
+		Variant: target.BuildVariant,
+		Path:    path,
+		Size:    size,
+		Cached:  cached,
+	})
+}
+
+// write stores the report at path, artifacts sorted by path. Returns false when nothing was built
+func (r *buildReport) write(path string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if len(r.Artifacts) == 0 {
+		return false, nil
+	}
+	sort.Slice(r.Artifacts, func(i, j int) bool { return r.Artifacts[i].Path < r.Artifacts[j].Path })
+	contents, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return false, err
+	}
+	return true, os.WriteFile(path, append(contents, '\n'), 0644)
+}
@@ -0,0 +1,342 @@
+package builder
+
+import (
+	"autobuild-go/internal/colors"
+	"autobuild-go/internal/models"
+	"bytes"
+	"crypto/sha256"
+	"debug/buildinfo"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reproducibleEnvVars are variables kept in the clean environment of reproducible builds. They locate tools,
+// caches and module sources, but do not change produced binaries
+var reproducibleEnvVars = map[string]bool{
+	"PATH": true, "HOME": true, "USERPROFILE": true, "SYSTEMROOT": true, "LOCALAPPDATA": true, "APPDATA": true,
+	"TMPDIR": true, "TEMP": true, "TMP": true,
+	"GOPATH": true, "GOROOT": true, "GOCACHE": true, "GOCACHEPROG": true, "GOMODCACHE": true,
+	"GOPROXY": true, "GONOPROXY": true, "GOPRIVATE": true, "GONOSUMDB": true, "GOSUMDB": true, "GOINSECURE": true, "GOAUTH": true,
+	"HTTP_PROXY": true, "HTTPS_PROXY": true, "NO_PROXY": true, "http_proxy": true, "https_proxy": true, "no_proxy": true,
+	"SSL_CERT_FILE": true, "SSL_CERT_DIR": true,
+}
+
+// buildEnv returns environment of `go build` for target. Reproducible builds start from a clean environment
+// with CGO disabled unless the build variant enables it
+func (g *GoBuilder) buildEnv(target GoBuilderTarget) []string {
+	var env []string
+	if g.profile.Reproducible {
+		for _, entry := range g.defaultEnv {
+			name, _, _ := strings.Cut(entry, "=")
+			if reproducibleEnvVars[name] || strings.HasPrefix(name, "AUTOBUILD_CACHE_") {
+				env = append(env, entry)
+			}
+		}
+		env = append(env, "GOTOOLCHAIN=local", "CGO_ENABLED=0")
+	} else {
+		env = append(env, g.defaultEnv...)
+	}
+	env = append(env, target.Env()...)
+	return append(env, g.variantEnv(target)...)
+}
+
+// buildTime returns build date of project artifacts, which is the source date in reproducible builds
+func (g *GoBuilder) buildTime(project models.Project) time.Time {
+	if !g.profile.Reproducible {
+		return g.buildDate
+	}
+	return g.sourceDate(project)
+}
+
+// sourceDate returns SOURCE_DATE_EPOCH, otherwise date of the last commit, so the date does not change between runs
+func (g *GoBuilder) sourceDate(project models.Project) time.Time {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		if seconds, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+			return time.Unix(seconds, 0)
+		}
+	}
+	if commitDate, err := time.Parse(time.RFC3339, g.moduleGitInfo(project).commitDate); err == nil {
+		return commitDate
+	}
+	return time.Unix(0, 0)
+}
+
+// verifyReproExec builds every target twice from a copy of the module in a temporary directory, each build
+// with empty Go build cache, and fails when the binaries differ
+func (g *GoBuilder) verifyReproExec(project models.Project) error {
+	sourceDir, err := g.reproSource(project)
+	if err != nil {
+		return fmt.Errorf("cannot verify reproducibility of %s: %v", project.AppName, err)
+	}
+	dir, err := os.MkdirTemp("", "autobuild-repro-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	srcDir := filepath.Join(dir, "src")
+	if err := copySources(sourceDir, resolvePath(project.BuildDir), srcDir); err != nil {
+		return fmt.Errorf("cannot copy sources of %s: %v", project.AppName, err)
+	}
+	moduleDir, err := filepath.Rel(sourceDir, resolvePath(project.RootDir))
+	if err != nil {
+		return err
+	}
+	moduleDir = filepath.Join(srcDir, moduleDir)
+
+	for _, target := range g.targets {
+		tn := time.Now()
+		name := target.OutputName(project.AppName)
+		colors.Icon(colors.Yellow, "\u226b", "Verifying reproducibility of app "+colors.Blue+"%s"+colors.Green+" (%s)"+colors.Reset, project.AppName, target)
+
+		flags, err := g.buildFlags(project, target)
+		if err != nil {
+			return err
+		}
+		env := g.buildEnv(target)
+
+		var binaries [2][]byte
+		for i := range binaries {
+			if binaries[i], err = g.reproBuild(project, moduleDir, flags, env, name); err != nil {
+				return fmt.Errorf("build %d of %s for %s failed: %v", i+1, project.AppName, target, err)
+			}
+		}
+
+		sums := [2][32]byte{sha256.Sum256(binaries[0]), sha256.Sum256(binaries[1])}
+		if sums[0] == sums[1] {
+			g.summary.add(project.AppName, "verify-repro (%s): reproducible, sha256 %s", target, hex.EncodeToString(sums[0][:])[:12])
+			colors.Success("App "+colors.Blue+"`%s`"+colors.Reset+" for architecture "+colors.Green+"%s"+colors.Reset+" is reproducible, verified in "+colors.Yellow+"%.1f"+colors.Reset+" seconds", project.AppName, target, time.Since(tn).Seconds())
+			continue
+		}
+
+		diff := binaryDiff(binaries[0], binaries[1])
+		g.summary.add(project.AppName, "verify-repro (%s): NOT reproducible, %s", target, diff)
+		keptDir := filepath.Join(project.BuildDir, "repro")
+		if err := os.MkdirAll(keptDir, os.ModePerm); err == nil {
+			for i, binary := range binaries {
+				os.WriteFile(filepath.Join(keptDir, fmt.Sprintf("%s.%d", name, i+1)), binary, 0o755)
+			}
+		}
+		return fmt.Errorf("app %s for %s is not reproducible, sha256 %s and %s: %s. Both builds stored in %s",
+			project.AppName, target, hex.EncodeToString(sums[0][:])[:12], hex.EncodeToString(sums[1][:])[:12], diff, keptDir)
+	}
+	return nil
+}
+
+// reproSource returns directory copied for reproducibility builds. It is the enclosing git worktree, so relative
+// replace directives and workspaces within it keep working, or the module itself outside of git.
+// Local modules outside of the copied directory are rejected, builds of the copy would not find them
+func (g *GoBuilder) reproSource(project models.Project) (string, error) {
+	rootDir := resolvePath(project.RootDir)
+	sourceDir := rootDir
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = project.RootDir
+	if output, err := cmd.Output(); err == nil {
+		sourceDir = resolvePath(strings.TrimSpace(string(output)))
+	}
+
+	dirs, err := g.localModuleDirs(project)
+	if err != nil {
+		return "", err
+	}
+	for _, dir := range dirs {
+		if rel, err := filepath.Rel(sourceDir, resolvePath(dir)); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("local module %s is outside of %s copied for verification builds", dir, sourceDir)
+		}
+	}
+	return sourceDir, nil
+}
+
+// localModuleDirs returns directories the module build reads besides module itself, which have to be copied with it:
+// targets of relative replace directives and the workspace with its relative modules
+func (g *GoBuilder) localModuleDirs(project models.Project) ([]string, error) {
+	goJSON := func(dir string, args ...string) (*goModEdit, error) {
+		cmd := exec.Command(g.toolchain.GoExec(), args...)
+		cmd.Dir = dir
+		cmd.Env = g.defaultEnv
+		var outBuf, errBuf bytes.Buffer
+		cmd.Stdout = &outBuf
+		cmd.Stderr = &errBuf
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("go %s failed: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(errBuf.String()))
+		}
+		var edit goModEdit
+		if err := json.Unmarshal(outBuf.Bytes(), &edit); err != nil {
+			return nil, fmt.Errorf("cannot parse go %s output: %v", strings.Join(args, " "), err)
+		}
+		return &edit, nil
+	}
+	// Absolute paths stay valid in the copy, only relative ones depend on its location
+	relativeDirs := func(base string, edit *goModEdit) []string {
+		var paths []string
+		for _, use := range edit.Use {
+			paths = append(paths, use.DiskPath)
+		}
+		for _, replace := range edit.Replace {
+			// Only local replacements have no version
+			if replace.New.Version == "" {
+				paths = append(paths, replace.New.Path)
+			}
+		}
+		var dirs []string
+		for _, path := range paths {
+			if path = filepath.FromSlash(path); !filepath.IsAbs(path) {
+				dirs = append(dirs, filepath.Join(base, path))
+			}
+		}
+		return dirs
+	}
+
+	edit, err := goJSON(project.RootDir, "mod", "edit", "-json")
+	if err != nil {
+		return nil, err
+	}
+	dirs := relativeDirs(project.RootDir, edit)
+
+	cmd := exec.Command(g.toolchain.GoExec(), "env", "GOWORK")
+	cmd.Dir = project.RootDir
+	cmd.Env = g.defaultEnv
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go env GOWORK failed: %v", err)
+	}
+	if work := strings.TrimSpace(string(output)); work != "" && work != "off" {
+		workEdit, err := goJSON(project.RootDir, "work", "edit", "-json", work)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, filepath.Dir(work))
+		dirs = append(dirs, relativeDirs(filepath.Dir(work), workEdit)...)
+	}
+	return dirs, nil
+}
+
+// goModEdit is a part of `go mod edit -json` and `go work edit -json` output
+type goModEdit struct {
+	Use []struct {
+		DiskPath string
+	}
+	Replace []struct {
+		New struct {
+			Path    string
+			Version string
+		}
+	}
+}
+
+// reproBuild builds the app from module copy in moduleDir to a new temporary directory and returns the binary
+func (g *GoBuilder) reproBuild(project models.Project, moduleDir string, flags []string, env []string, name string) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "autobuild-repro-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	mainDir, err := filepath.Rel(project.RootDir, project.AppMainSrcDir)
+	if err != nil {
+		return nil, err
+	}
+
+	outputPath := filepath.Join(dir, name)
+	buildArgs := append([]string{"build", "-o", outputPath}, flags...)
+	buildArgs = append(buildArgs, "./"+filepath.ToSlash(mainDir))
+	cmd := exec.Command(g.toolchain.GoExec(), buildArgs...)
+	cmd.Dir = moduleDir
+	// Fresh build cache, so the second build does not reuse packages compiled by the first one
+	cmd.Env = append(append([]string{}, env...), "GOCACHE="+filepath.Join(dir, "gocache"), "GOCACHEPROG=")
+
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%v\n%s\n%s", err, outBuf.String(), errBuf.String())
+	}
+	return os.ReadFile(outputPath)
+}
+
+// copySources copies sourceDir to dest, without version control metadata and build directory
+func copySources(sourceDir string, buildDir string, dest string) error {
+	return filepath.WalkDir(sourceDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+
+		switch {
+		case entry.IsDir() && (entry.Name() == ".git" || path == buildDir):
+			return filepath.SkipDir
+		case entry.IsDir():
+			return os.MkdirAll(target, os.ModePerm)
+		case entry.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case entry.Type().IsRegular():
+			return copyFile(path, target)
+		}
+		return nil
+	})
+}
+
+// binaryDiff describes how two builds differ: sizes, differing bytes and differing build settings
+func binaryDiff(a []byte, b []byte) string {
+	var parts []string
+	if len(a) != len(b) {
+		parts = append(parts, fmt.Sprintf("sizes %d and %d bytes", len(a), len(b)))
+	}
+	differing, first := 0, -1
+	for i := 0; i < min(len(a), len(b)); i++ {
+		if a[i] != b[i] {
+			differing++
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+	if first >= 0 {
+		parts = append(parts, fmt.Sprintf("%d bytes differ, first at offset %#x", differing, first))
+	}
+
+	infoA, errA := buildinfo.Read(bytes.NewReader(a))
+	infoB, errB := buildinfo.Read(bytes.NewReader(b))
+	if errA == nil && errB == nil {
+		settings := map[string]string{}
+		for _, setting := range infoA.Settings {
+			settings[setting.Key] = setting.Value
+		}
+		changed := map[string]bool{}
+		for _, setting := range infoB.Settings {
+			if value, ok := settings[setting.Key]; !ok || value != setting.Value {
+				changed[setting.Key] = true
+			}
+			delete(settings, setting.Key)
+		}
+		for key := range settings {
+			changed[key] = true
+		}
+		var keys []string
+		for key := range changed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if len(keys) > 0 {
+			parts = append(parts, "build settings differ: "+strings.Join(keys, ", "))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
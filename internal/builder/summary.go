@@ -0,0 +1,48 @@
+package builder
+
+import (
+	"autobuild-go/internal/colors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// runSummary collects per project notes from stages and prints them at the end of the run
+type runSummary struct {
+	mutex sync.Mutex
+	notes map[string][]string
+}
+
+func newRunSummary() *runSummary {
+	return &runSummary{notes: map[string][]string{}}
+}
+
+// add records a note for the project
+func (s *runSummary) add(project string, strfmt string, args ...interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.notes[project] = append(s.notes[project], fmt.Sprintf(strfmt, args...))
+}
+
+// print outputs collected notes grouped by project
+func (s *runSummary) print() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.notes) == 0 {
+		return
+	}
+
+	var projects []string
+	for project := range s.notes {
+		projects = append(projects, project)
+	}
+	sort.Strings(projects)
+
+	colors.HorizontalLine("Summary")
+	for _, project := range projects {
+		fmt.Printf("    %s%s%s\n", colors.Blue, project, colors.Reset)
+		for _, note := range s.notes[project] {
+			fmt.Printf("\t%s\n", note)
+		}
+	}
+}
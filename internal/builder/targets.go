@@ -0,0 +1,168 @@
+package builder
+
+import (
+	"autobuild-go/internal/models"
+	"autobuild-go/internal/utils"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// variantRule describes microarchitecture variable of an architecture and its accepted values
+type variantRule struct {
+	env    string         // Environment variable selecting the variant
+	field  string         // Name of the target field in autobuild.yaml
+	values *regexp.Regexp // Accepted values
+	since  string         // First Go version supporting the variable
+}
+
+var archVariants = map[string]variantRule{
+	"arm":      {"GOARM", "goarm", regexp.MustCompile(`^[5-7](,(softfloat|hardfloat))?$`), ""},
+	"amd64":    {"GOAMD64", "goamd64", regexp.MustCompile(`^v[1-4]$`), "1.18"},
+	"arm64":    {"GOARM64", "goarm64", regexp.MustCompile(`^v(8\.[0-9]|9\.[0-5])(,(lse|crypto))*$`), "1.23"},
+	"386":      {"GO386", "go386", regexp.MustCompile(`^(sse2|softfloat)$`), "1.16"},
+	"mips":     {"GOMIPS", "gomips", regexp.MustCompile(`^(hardfloat|softfloat)$`), ""},
+	"mipsle":   {"GOMIPS", "gomips", regexp.MustCompile(`^(hardfloat|softfloat)$`), ""},
+	"mips64":   {"GOMIPS64", "gomips", regexp.MustCompile(`^(hardfloat|softfloat)$`), ""},
+	"mips64le": {"GOMIPS64", "gomips", regexp.MustCompile(`^(hardfloat|softfloat)$`), ""},
+	"ppc64":    {"GOPPC64", "goppc64", regexp.MustCompile(`^power(8|9|10)$`), ""},
+	"ppc64le":  {"GOPPC64", "goppc64", regexp.MustCompile(`^power(8|9|10)$`), ""},
+}
+
+// validVariantName matches build variant names, which are part of artifact names
+var validVariantName = regexp.MustCompile(`^[A-Za-z0-9._]+$`)
+
+// newerVariants are variant values added after the variable itself
+var newerVariants = []struct {
+	env    string
+	values *regexp.Regexp
+	since  string
+}{
+	{"GOARM", regexp.MustCompile(`,`), "1.22"},
+	{"GOPPC64", regexp.MustCompile(`^power10$`), "1.21"},
+}
+
+// String returns target description used in logs and summary, e.g. `linux:armv7` or `enterprise linux:amd64`
+func (t GoBuilderTarget) String() string {
+	if t.BuildVariant == "" {
+		return t.GOOS + ":" + t.Arch()
+	}
+	return t.BuildVariant + " " + t.GOOS + ":" + t.Arch()
+}
+
+// Arch returns architecture with variant used in artifact names, e.g. armv7 or amd64v3
+func (t GoBuilderTarget) Arch() string {
+	if t.Variant == "" {
+		return t.GOARCH
+	}
+	variant := strings.ReplaceAll(t.Variant, ",", "_")
+	if variant[0] >= '0' && variant[0] <= '9' {
+		variant = "v" + variant
+	}
+	return t.GOARCH + variant
+}
+
+// Env returns environment selecting target platform
+func (t GoBuilderTarget) Env() []string {
+	env := []string{"GOOS=" + t.GOOS, "GOARCH=" + t.GOARCH}
+	if t.VariantEnv != "" {
+		env = append(env, t.VariantEnv+"="+t.Variant)
+	}
+	return env
+}
+
+// newTargets collects targets from `os` and `targets` profile sections and validates their variants against toolchain.
+// With build variants declared in profile, targets are multiplied by variants
+func newTargets(profile models.Profile, toolchain models.GoToolchain) (GoBuilderTargets, error) {
+	targets := GoBuilderTargets{}
+	add := func(target GoBuilderTarget) {
+		for _, existing := range targets {
+			if existing == target {
+				return
+			}
+		}
+		targets = append(targets, target)
+	}
+
+	for osName, osArch := range profile.OS {
+		for _, archTarget := range osArch {
+			add(GoBuilderTarget{GOOS: osName, GOARCH: archTarget, EXECSUFFIX: execSuffix(osName)})
+		}
+	}
+
+	for _, target := range profile.Targets {
+		if target.OS == "" || target.Arch == "" {
+			return nil, fmt.Errorf("target needs both os and arch: %+v", target)
+		}
+		builderTarget := GoBuilderTarget{GOOS: target.OS, GOARCH: target.Arch, EXECSUFFIX: execSuffix(target.OS)}
+
+		var field, value string
+		for name, v := range map[string]string{
+			"goarm":   target.GOARM,
+			"goamd64": target.GOAMD64,
+			"goarm64": target.GOARM64,
+			"go386":   target.GO386,
+			"gomips":  target.GOMIPS,
+			"goppc64": target.GOPPC64,
+		} {
+			if v == "" {
+				continue
+			}
+			if field != "" {
+				return nil, fmt.Errorf("target %s/%s sets both %s and %s", target.OS, target.Arch, field, name)
+			}
+			field, value = name, v
+		}
+
+		if field != "" {
+			rule, ok := archVariants[target.Arch]
+			if !ok || rule.field != field {
+				return nil, fmt.Errorf("%s does not apply to architecture %s", field, target.Arch)
+			}
+			if !rule.values.MatchString(value) {
+				return nil, fmt.Errorf("invalid %s=%s for %s/%s", rule.env, value, target.OS, target.Arch)
+			}
+			if rule.since != "" && utils.CompareVersions(toolchain.Version, rule.since) < 0 {
+				return nil, fmt.Errorf("%s requires Go %s or newer, toolchain is %s", rule.env, rule.since, toolchain.Version)
+			}
+			for _, newer := range newerVariants {
+				if newer.env == rule.env && newer.values.MatchString(value) && utils.CompareVersions(toolchain.Version, newer.since) < 0 {
+					return nil, fmt.Errorf("%s=%s requires Go %s or newer, toolchain is %s", rule.env, value, newer.since, toolchain.Version)
+				}
+			}
+			builderTarget.VariantEnv = rule.env
+			builderTarget.Variant = value
+		}
+		add(builderTarget)
+	}
+
+	if len(profile.Variants) == 0 {
+		return targets, nil
+	}
+	var names []string
+	for name := range profile.Variants {
+		if !validVariantName.MatchString(name) {
+			return nil, fmt.Errorf("invalid variant name `%s`, only letters, digits, `.` and `_` are allowed", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Every target is built once per variant
+	var variantTargets GoBuilderTargets
+	for _, name := range names {
+		for _, target := range targets {
+			target.BuildVariant = name
+			variantTargets = append(variantTargets, target)
+		}
+	}
+	return variantTargets, nil
+}
+
+func execSuffix(osName string) string {
+	if osName == "windows" {
+		return ".exe"
+	}
+	return ""
+}
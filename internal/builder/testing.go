@@ -0,0 +1,353 @@
+package builder
+
+import (
+	"autobuild-go/internal/colors"
+	"autobuild-go/internal/models"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// testEvent is a single event of `go test -json` output
+type testEvent struct {
+	Action  string `json:"Action"`
+	Package string `json:"Package"`
+	Test    string `json:"Test"`
+	Output  string `json:"Output"`
+}
+
+// testRun is the parsed result of one `go test -json` invocation
+type testRun struct {
+	output string              // Textual test output reconstructed from events
+	stderr string              // Standard error of the go command, e.g. build failures
+	passed map[string][]string // Package to top-level tests which passed
+	failed map[string][]string // Package to top-level tests which failed
+}
+
+func (g *GoBuilder) testExec(project models.Project, toolchain models.GoToolchain) error {
+	tn := time.Now()
+	goTag := "go" + toolchain.Version
+	// Only a test matrix needs the Go version in file names, single version runs keep the names they always had
+	coverageName, logPrefix := "coverage-"+project.AppName+".txt", "test-"
+	if len(g.testToolchains) > 1 {
+		coverageName, logPrefix = "coverage-"+project.AppName+"-"+goTag+".txt", "test-"+goTag+"-"
+	}
+
+	packages := []string{"./..."}
+	if g.changedSince != "" {
+		affected, full := g.affectedPackages(project)
+		if !full && len(affected) == 0 {
+			colors.Success("Skipped testing application "+colors.Blue+"`%s`"+colors.Reset+" with "+colors.Green+"%s"+colors.Reset+", no packages affected by changes since %s", project.AppName, goTag, g.changedSince)
+			g.summary.add(project.AppName, "test (%s): skipped, no affected packages", goTag)
+			return nil
+		}
+		if !full {
+			packages = affected
+		}
+	}
+	colors.Icon(colors.Yellow, "\u226b", "Testing app "+colors.Blue+"%s"+colors.Reset+" with "+colors.Green+"%s"+colors.Reset, project.AppName, goTag)
+
+	// Prepare the test command: go test -json -coverprofile=coverage-app[-goX].txt [profile options] ./... (or affected packages)
+	args := append([]string{fmt.Sprintf("-coverprofile=%s/%s", project.BuildDir, coverageName)}, g.testArgs(false)...)
+	run, err := g.goTest(project, toolchain, append(args, packages...)...)
+	g.testHistory.load(g.historyPath(project))
+
+	if err == nil {
+		g.testHistory.record(project, run.passed, nil, nil)
+		colors.Success("Successfully tested application "+colors.Blue+"`%s`"+colors.Reset+" with "+colors.Green+"%s"+colors.Reset+" in "+colors.Yellow+"%.1f"+colors.Reset+" seconds", project.AppName, goTag, time.Since(tn).Seconds())
+		return nil
+	}
+
+	// Without failed tests the failure is not a test failure (e.g. build error), retrying would not help
+	if len(run.failed) == 0 || g.profile.Test.Retries == 0 {
+		g.testHistory.record(project, run.passed, nil, run.failed)
+		persistLog(logPrefix, *bytes.NewBufferString(run.output), *bytes.NewBufferString(run.stderr), project.BuildDir, project.AppName)
+		return fmt.Errorf("error testing %s with %s: %v. Logs created", project.AppName, goTag, err)
+	}
+
+	failed := run.failed
+	flaky := map[string][]string{}
+	output, stderr := run.output, run.stderr
+	for attempt := 1; attempt <= g.profile.Test.Retries && len(failed) > 0; attempt++ {
+		stillFailing := map[string][]string{}
+		for pkg, tests := range failed {
+			colors.Icon(colors.Yellow, "\u21bb", "Retrying %s in %s (attempt %d of %d)", strings.Join(tests, ", "), pkg, attempt, g.profile.Test.Retries)
+
+			retryArgs := append(g.testArgs(true), "-count=1", "-run="+testsPattern(tests), pkg)
+			retry, _ := g.goTest(project, toolchain, retryArgs...)
+			output += fmt.Sprintf("\n=== RETRY %d of %s: %s\n", attempt, pkg, strings.Join(tests, ", ")) + retry.output
+			stderr += retry.stderr
+
+			for _, test := range tests {
+				if contains(retry.passed[pkg], test) {
+					flaky[pkg] = append(flaky[pkg], test)
+				} else {
+					stillFailing[pkg] = append(stillFailing[pkg], test)
+				}
+			}
+		}
+		failed = stillFailing
+	}
+
+	g.testHistory.record(project, run.passed, flaky, failed)
+	for pkg, tests := range flaky {
+		for _, test := range tests {
+			colors.WarnLog("Flaky test %s.%s passed on retry", pkg, test)
+		}
+		g.summary.add(project.AppName, "test (%s): flaky %s in %s", goTag, strings.Join(tests, ", "), pkg)
+	}
+
+	if len(failed) > 0 {
+		persistLog(logPrefix, *bytes.NewBufferString(output), *bytes.NewBufferString(stderr), project.BuildDir, project.AppName)
+		return fmt.Errorf("error testing %s with %s: tests failed after %d retries. Logs created", project.AppName, goTag, g.profile.Test.Retries)
+	}
+	colors.Success("Successfully tested application "+colors.Blue+"`%s`"+colors.Reset+" with "+colors.Green+"%s"+colors.Reset+" after retrying flaky tests in "+colors.Yellow+"%.1f"+colors.Reset+" seconds", project.AppName, goTag, time.Since(tn).Seconds())
+	return nil
+}
+
+// goTest runs `go test -json` with given arguments and parses its events
+func (g *GoBuilder) goTest(project models.Project, toolchain models.GoToolchain, args ...string) (testRun, error) {
+	cmd := exec.Command(toolchain.GoExec(), append([]string{"test", "-json"}, args...)...)
+	cmd.Dir = project.RootDir
+	cmd.Env = g.toolchainEnv(toolchain)
+	if g.profile.Test.Race {
+		// Race detector requires cgo
+		cmd.Env = append(cmd.Env, "CGO_ENABLED=1")
+	}
+
+	// Capture output
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	err := cmd.Run()
+
+	run := testRun{passed: map[string][]string{}, failed: map[string][]string{}}
+	var text strings.Builder
+	scanner := bufio.NewScanner(&outBuf)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var event testEvent
+		if json.Unmarshal(scanner.Bytes(), &event) != nil {
+			// Build errors and other non-JSON output
+			text.WriteString(scanner.Text() + "\n")
+			continue
+		}
+		text.WriteString(event.Output)
+
+		// Subtests are retried through their top-level test
+		if event.Test == "" || strings.Contains(event.Test, "/") {
+			continue
+		}
+		switch event.Action {
+		case "pass":
+			run.passed[event.Package] = append(run.passed[event.Package], event.Test)
+		case "fail":
+			if !contains(run.failed[event.Package], event.Test) {
+				run.failed[event.Package] = append(run.failed[event.Package], event.Test)
+			}
+		}
+	}
+	run.output = text.String()
+	run.stderr = errBuf.String()
+
+	// With -count > 1 a test may both pass and fail, such test counts as failed
+	for pkg, tests := range run.failed {
+		var passed []string
+		for _, test := range run.passed[pkg] {
+			if !contains(tests, test) && !contains(passed, test) {
+				passed = append(passed, test)
+			}
+		}
+		run.passed[pkg] = passed
+	}
+	return run, err
+}
+
+// testArgs returns `go test` flags configured in `test` section of the profile.
+// Retries select tests on their own, so run, skip and count options are left out
+func (g *GoBuilder) testArgs(retry bool) []string {
+	settings := g.profile.Test
+	var args []string
+	if settings.Race {
+		args = append(args, "-race")
+	}
+	if settings.Shuffle != "" {
+		args = append(args, "-shuffle="+settings.Shuffle)
+	}
+	if settings.Count > 0 && !retry {
+		args = append(args, fmt.Sprintf("-count=%d", settings.Count))
+	}
+	if settings.Timeout != "" {
+		args = append(args, "-timeout="+settings.Timeout)
+	}
+	if len(settings.Tags) > 0 {
+		args = append(args, "-tags="+strings.Join(settings.Tags, ","))
+	}
+	if settings.Run != "" && !retry {
+		args = append(args, "-run="+settings.Run)
+	}
+	if settings.Skip != "" && !retry {
+		args = append(args, "-skip="+settings.Skip)
+	}
+	if settings.Short {
+		args = append(args, "-short")
+	}
+	return append(args, settings.Args...)
+}
+
+// historyPath returns location of flaky test history file
+func (g *GoBuilder) historyPath(project models.Project) string {
+	if g.profile.Test.History == "" {
+		return filepath.Join(project.BuildDir, "test-history.json")
+	}
+	if filepath.IsAbs(g.profile.Test.History) {
+		return g.profile.Test.History
+	}
+	return filepath.Join(filepath.Dir(project.BuildDir), g.profile.Test.History)
+}
+
+// testsPattern returns -run pattern matching exactly given top-level tests
+func testsPattern(tests []string) string {
+	quoted := make([]string, 0, len(tests))
+	for _, test := range tests {
+		quoted = append(quoted, regexp.QuoteMeta(test))
+	}
+	return "^(" + strings.Join(quoted, "|") + ")$"
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// testStats are counters of a single test across runs
+type testStats struct {
+	Runs     int `json:"runs"`
+	Flakes   int `json:"flakes"`
+	Failures int `json:"failures"`
+}
+
+// testHistory tracks flake rates of tests across runs in a JSON file
+type testHistory struct {
+	mutex sync.Mutex
+	path  string
+	tests map[string]*testStats
+	flaky map[string]bool // Tests which flaked in this run
+}
+
+// load reads history file once, subsequent calls are ignored
+func (h *testHistory) load(path string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.tests != nil {
+		return
+	}
+
+	h.path = path
+	h.tests = map[string]*testStats{}
+	h.flaky = map[string]bool{}
+	if contents, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(contents, &h.tests); err != nil {
+			colors.WarnLog("Ignoring invalid test history `%s`: %v", path, err)
+			h.tests = map[string]*testStats{}
+		}
+	}
+}
+
+// record counts a run of every test, flaky and failed ones included
+func (h *testHistory) record(project models.Project, passed, flaky, failed map[string][]string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	stats := func(pkg, test string) *testStats {
+		key := pkg + "." + test
+		if h.tests[key] == nil {
+			h.tests[key] = &testStats{}
+		}
+		h.tests[key].Runs++
+		return h.tests[key]
+	}
+	for pkg, tests := range passed {
+		for _, test := range tests {
+			stats(pkg, test)
+		}
+	}
+	for pkg, tests := range flaky {
+		for _, test := range tests {
+			stats(pkg, test).Flakes++
+			h.flaky[pkg+"."+test] = true
+		}
+	}
+	for pkg, tests := range failed {
+		for _, test := range tests {
+			stats(pkg, test).Failures++
+		}
+	}
+}
+
+// save writes history file when tests were run
+func (h *testHistory) save() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.tests == nil {
+		return nil
+	}
+
+	contents, err := json.MarshalIndent(h.tests, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(h.path, contents, 0o644)
+}
+
+// summarize adds tests flaking most often across recorded runs to the run summary
+func (h *testHistory) summarize(summary *runSummary) {
+	const top = 5
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var names []string
+	for name, stats := range h.tests {
+		if stats.Flakes > 0 {
+			names = append(names, name)
+		}
+	}
+	rate := func(name string) float64 {
+		return float64(h.tests[name].Flakes) / float64(h.tests[name].Runs)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if rate(names[i]) != rate(names[j]) {
+			return rate(names[i]) > rate(names[j])
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > top {
+		names = names[:top]
+	}
+
+	for _, name := range names {
+		stats := h.tests[name]
+		note := fmt.Sprintf("%s flaked in %d of %d runs (%.0f%%)", name, stats.Flakes, stats.Runs, 100*rate(name))
+		if h.flaky[name] {
+			note += ", flaked in this run"
+		}
+		summary.add("Most flaky tests", "%s", note)
+	}
+}
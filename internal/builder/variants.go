@@ -0,0 +1,74 @@
+package builder
+
+import (
+	"autobuild-go/internal/models"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// buildFlags returns `go build` flags of the target: build variant settings and injected variables
+func (g *GoBuilder) buildFlags(project models.Project, target GoBuilderTarget) ([]string, error) {
+	variant := g.profile.Variants[target.BuildVariant]
+
+	var flags []string
+	if len(variant.Tags) > 0 {
+		flags = append(flags, "-tags="+strings.Join(variant.Tags, ","))
+	}
+	if variant.Trimpath || g.profile.Reproducible {
+		flags = append(flags, "-trimpath")
+	}
+	if g.profile.Reproducible {
+		// VCS stamp records worktree state, which differs between checkouts of the same commit
+		flags = append(flags, "-buildvcs=false")
+	}
+	if variant.Gcflags != "" {
+		flags = append(flags, "-gcflags="+variant.Gcflags)
+	}
+
+	// Only the last -ldflags is used by go build, variant flags and version injection are passed together
+	var ldflags []string
+	if variant.Ldflags != "" {
+		ldflags = append(ldflags, variant.Ldflags)
+	}
+	if _, configured := g.profile.Inject["main.releaseVersion"]; g.currentRelease != "" && !configured {
+		flag, err := quoteFlag("main.releaseVersion=" + g.currentRelease)
+		if err != nil {
+			return nil, fmt.Errorf("invalid release version: %v", err)
+		}
+		ldflags = append(ldflags, "-X "+flag)
+	}
+	injected, err := g.injectFlags(project, target)
+	if err != nil {
+		return nil, err
+	}
+	ldflags = append(ldflags, injected...)
+	if len(ldflags) > 0 {
+		flags = append(flags, "--ldflags", strings.Join(ldflags, " "))
+	}
+	return flags, nil
+}
+
+// variantEnv returns environment overrides of the target build variant
+func (g *GoBuilder) variantEnv(target GoBuilderTarget) []string {
+	variant := g.profile.Variants[target.BuildVariant]
+
+	var env []string
+	if variant.CGOEnabled != nil {
+		cgo := "0"
+		if *variant.CGOEnabled {
+			cgo = "1"
+		}
+		env = append(env, "CGO_ENABLED="+cgo)
+	}
+
+	var names []string
+	for name := range variant.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+variant.Env[name])
+	}
+	return env
+}
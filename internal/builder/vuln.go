@@ -0,0 +1,182 @@
+package builder
+
+import (
+	"autobuild-go/internal/colors"
+	"autobuild-go/internal/models"
+	"autobuild-go/internal/utils"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// govulncheckMessage is the subset of govulncheck JSON stream messages used by the builder
+type govulncheckMessage struct {
+	Finding *struct {
+		OSV          string `json:"osv"`
+		FixedVersion string `json:"fixed_version"`
+		Trace        []struct {
+			Module   string `json:"module"`
+			Version  string `json:"version"`
+			Package  string `json:"package"`
+			Function string `json:"function"`
+			Position *struct {
+				Filename string `json:"filename"`
+				Line     int    `json:"line"`
+			} `json:"position"`
+		} `json:"trace"`
+	} `json:"finding"`
+}
+
+// vulnerability is a reachable vulnerability aggregated from govulncheck findings
+type vulnerability struct {
+	ID           string
+	Module       string
+	FixedVersion string
+	CalledFrom   string
+}
+
+// vulnExec scans module sources once per module and, when build stage produced them, every built binary of the app
+func (g *GoBuilder) vulnExec(project models.Project) error {
+	err := g.onceForModule("vuln", project, func() error {
+		reportPath := filepath.Join(project.BuildDir, fmt.Sprintf("vuln-module-%s.json", moduleFileName(project)))
+		return g.govulncheck(project, moduleSummary(project), "module "+project.RootDir, reportPath, "./...")
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, ok := g.stages["build"]; !ok {
+		return nil
+	}
+	for _, target := range g.targets {
+		outputPath := filepath.Join(project.BuildDir, target.OutputName(project.AppName))
+		reportPath := filepath.Join(project.BuildDir, fmt.Sprintf("vuln-%s.json", target.OutputName(project.AppName)))
+		if err := g.govulncheck(project, project.AppName, "binary "+filepath.Base(outputPath), reportPath, "-mode=binary", outputPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// govulncheck runs govulncheck with JSON output stored in reportPath and fails on reachable vulnerabilities
+func (g *GoBuilder) govulncheck(project models.Project, summaryKey string, subject string, reportPath string, args ...string) error {
+	tn := time.Now()
+	colors.Icon(colors.Yellow, "\u226b", "Scanning "+colors.Blue+"%s"+colors.Reset+" for known vulnerabilities", subject)
+
+	db := g.profile.Vuln.DB
+	if db != "" && utils.IsLocalURL(db) {
+		path, err := filepath.Abs(utils.LocalPath(db))
+		if err != nil {
+			return fmt.Errorf("invalid vulnerability database `%s`: %v", db, err)
+		}
+		db = "file://" + filepath.ToSlash(path)
+		if !strings.HasPrefix(db, "file:///") {
+			// Windows drive letter paths
+			db = "file:///" + strings.TrimPrefix(db, "file://")
+		}
+	} else if g.offline {
+		return fmt.Errorf("vuln stage needs local vulnerability database (vuln.db) in offline mode")
+	}
+
+	vulnArgs := []string{"-format=json"}
+	if db != "" {
+		vulnArgs = append(vulnArgs, "-db="+db)
+	}
+	vulnArgs = append(vulnArgs, args...)
+
+	vulnExec, toolArgs := g.toolCommand(project, "govulncheck")
+	cmd := exec.Command(vulnExec, append(toolArgs, vulnArgs...)...)
+	cmd.Dir = project.RootDir
+	cmd.Env = g.defaultEnv
+
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+
+	// With JSON output govulncheck exits with 0 regardless of findings, so any error is a failure to scan
+	if err := cmd.Run(); err != nil {
+		persistLog("vuln-", outBuf, errBuf, project.BuildDir, project.AppName)
+		return fmt.Errorf("vulnerability scan of %s failed: %v. Logs created", subject, err)
+	}
+	if err := os.WriteFile(reportPath, outBuf.Bytes(), 0o644); err != nil {
+		colors.ErrLog("Cannot write vulnerability report `%s`: %v", reportPath, err)
+	}
+
+	reachable, imported, err := parseGovulncheck(outBuf.Bytes())
+	if err != nil {
+		return fmt.Errorf("cannot parse govulncheck output for %s: %v", subject, err)
+	}
+
+	summary := fmt.Sprintf("vuln (%s): %d reachable, %d imported but not called", subject, len(reachable), imported)
+	g.summary.add(summaryKey, "%s", summary)
+
+	if len(reachable) > 0 {
+		for _, vuln := range reachable {
+			fixed := "no fix available"
+			if vuln.FixedVersion != "" {
+				fixed = "fixed in " + vuln.FixedVersion
+			}
+			colors.ErrLog("%s in %s (%s), called from %s", vuln.ID, vuln.Module, fixed, vuln.CalledFrom)
+		}
+		return fmt.Errorf("%d reachable vulnerabilities in %s. Report stored in %s", len(reachable), subject, reportPath)
+	}
+	colors.Success("No reachable vulnerabilities in "+colors.Blue+"%s"+colors.Reset+", scanned in "+colors.Yellow+"%.1f"+colors.Reset+" seconds", subject, time.Since(tn).Seconds())
+	return nil
+}
+
+// parseGovulncheck reads govulncheck JSON stream. Finding is reachable when the innermost frame of its trace names a function
+func parseGovulncheck(output []byte) ([]vulnerability, int, error) {
+	reachable := map[string]vulnerability{}
+	imported := map[string]bool{}
+
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	for {
+		var message govulncheckMessage
+		if err := decoder.Decode(&message); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, 0, err
+		}
+		finding := message.Finding
+		if finding == nil || len(finding.Trace) == 0 {
+			continue
+		}
+
+		if finding.Trace[0].Function == "" {
+			imported[finding.OSV] = true
+			continue
+		}
+		if _, ok := reachable[finding.OSV]; ok {
+			continue
+		}
+
+		calledFrom := "unknown location"
+		for _, frame := range finding.Trace {
+			if frame.Position != nil && frame.Position.Filename != "" {
+				calledFrom = fmt.Sprintf("%s:%d", frame.Position.Filename, frame.Position.Line)
+			}
+		}
+		reachable[finding.OSV] = vulnerability{
+			ID:           finding.OSV,
+			Module:       finding.Trace[0].Module + "@" + finding.Trace[0].Version,
+			FixedVersion: finding.FixedVersion,
+			CalledFrom:   calledFrom,
+		}
+	}
+
+	var result []vulnerability
+	for id, vuln := range reachable {
+		delete(imported, id)
+		result = append(result, vuln)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, len(imported), nil
+}
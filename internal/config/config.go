@@ -26,9 +26,13 @@ func loadConfig(filename string) (*models.Config, error) {
 	return &config, nil
 }
 
-func parseArg() (string, string) {
+// ParseArgs parses command line flags. Project path is the first positional argument and defaults to current directory
+func ParseArgs() models.Args {
 	profile := flag.String("profile", "default", "Specify the profile to use")
 	release := flag.String("release", "", "Inject release version to main.releaseVersion variable")
+	offline := flag.Bool("offline", false, "Use only installed toolchains and cached tools, never access the network")
+	fix := flag.Bool("fix", false, "Let fmt and tidy stages rewrite files in place instead of failing")
+	changedSince := flag.String("changed-since", "", "Test only packages affected by files changed since given git ref")
 	help := flag.Bool("help", false, "Show this help")
 	flag.Parse()
 
@@ -36,33 +40,56 @@ func parseArg() (string, string) {
 		flag.Usage()
 		os.Exit(1)
 	}
-	return *profile, *release
+
+	path := flag.Arg(0)
+	if path == "" {
+		path = "."
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		colors.ErrLog("Error trying to get project dir: %v", err)
+		os.Exit(1)
+	}
+
+	return models.Args{
+		Profile:      *profile,
+		Release:      *release,
+		Offline:      *offline,
+		Fix:          *fix,
+		ChangedSince: *changedSince,
+		Path:         path,
+	}
 }
 
-func GetProfileConfig(projectPath string) models.SelectedConfig {
-	profile, release := parseArg()
+func GetProfileConfig(args models.Args) models.SelectedConfig {
+	projectPath := args.Path
+	profile := args.Profile
 
 	fpath := filepath.Join(projectPath, "autobuild.yaml")
 	if _, err := os.Lstat(fpath); err != nil {
 		colors.Icon(colors.Yellow, "!!", "No autobuild.yaml in `%s` directory. Using default", projectPath)
-		return models.DefaultConfig(projectPath)
+		return withArgs(models.DefaultConfig(projectPath), args)
 	}
 
 	cfg, err := loadConfig(fpath)
 	if err != nil {
 		colors.Icon(colors.Red, "!!", "Cannot load configuration from `autobuild.yaml` in `%s` directory: %v. Using default", projectPath, err)
-		return models.DefaultConfig(projectPath)
+		return withArgs(models.DefaultConfig(projectPath), args)
 	}
 
 	if val, ok := cfg.Profiles[profile]; ok {
 		hdir, _ := os.UserHomeDir()
 		cfg.Toolchain.Location = strings.Replace(cfg.Toolchain.Location, "$HOME", hdir, -1)
+		cfg.Cache.Dir = strings.Replace(cfg.Cache.Dir, "$HOME", hdir, -1)
 		colors.Success("Profile selected: %s%s%s", colors.Blue, profile, colors.Reset)
-		return models.SelectedConfig{
-			Profile:        val,
-			Toolchain:      cfg.Toolchain,
-			CurrentVersion: release,
-		}
+		return withArgs(models.SelectedConfig{
+			Profile:   val,
+			Toolchain: cfg.Toolchain,
+			Mirrors:   cfg.Mirrors,
+			Download:  cfg.Download,
+			Tools:     cfg.Tools,
+			Cache:     cfg.Cache,
+		}, args)
 	} else {
 		var profiles []string
 		for profName, _ := range cfg.Profiles {
@@ -72,5 +99,40 @@ func GetProfileConfig(projectPath string) models.SelectedConfig {
 		os.Exit(1)
 	}
 
-	return models.DefaultConfig(projectPath)
+	return withArgs(models.DefaultConfig(projectPath), args)
+}
+
+// GetBaseConfig loads toolchain and mirrors configuration from `autobuild.yaml` in projectPath without selecting a profile.
+// Used by subcommands which do not build projects
+func GetBaseConfig(projectPath string, offline bool) models.SelectedConfig {
+	conf := models.DefaultConfig(projectPath)
+	conf.Offline = offline
+
+	cfg, err := loadConfig(filepath.Join(projectPath, "autobuild.yaml"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			colors.Icon(colors.Red, "!!", "Cannot load configuration from `autobuild.yaml` in `%s` directory: %v. Using default", projectPath, err)
+		}
+		return conf
+	}
+
+	hdir, _ := os.UserHomeDir()
+	cfg.Toolchain.Location = strings.Replace(cfg.Toolchain.Location, "$HOME", hdir, -1)
+	cfg.Cache.Dir = strings.Replace(cfg.Cache.Dir, "$HOME", hdir, -1)
+	conf.Toolchain = cfg.Toolchain
+	conf.Mirrors = cfg.Mirrors
+	conf.Download = cfg.Download
+	conf.Tools = cfg.Tools
+	conf.Cache = cfg.Cache
+	return conf
+}
+
+// withArgs applies command line arguments on top of selected configuration
+func withArgs(conf models.SelectedConfig, args models.Args) models.SelectedConfig {
+	conf.CurrentVersion = args.Release
+	conf.ProfileName = args.Profile
+	conf.Offline = args.Offline
+	conf.Fix = args.Fix
+	conf.ChangedSince = args.ChangedSince
+	return conf
 }
@@ -0,0 +1,286 @@
+package downloader
+
+import (
+	"autobuild-go/internal/colors"
+	"autobuild-go/internal/models"
+	"autobuild-go/internal/utils"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	defaultRetries = 3
+	defaultTimeout = 30 * time.Second
+	maxBackoff     = 30 * time.Second
+)
+
+// Downloader fetches resources over HTTP with retries, resume and progress reporting. Local paths and file:// URLs are read directly
+type Downloader struct {
+	client  *http.Client
+	retries int
+	timeout time.Duration
+	backoff time.Duration
+}
+
+// permanentError marks failures which are not worth retrying (e.g. 404)
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+// New creates Downloader from `download` configuration section
+func New(conf models.Download) (*Downloader, error) {
+	retries := defaultRetries
+	if conf.Retries != nil {
+		retries = *conf.Retries
+	}
+
+	timeout := defaultTimeout
+	if conf.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+			return nil, fmt.Errorf("invalid download timeout `%s`: %v", conf.Timeout, err)
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = timeout
+	transport.ResponseHeaderTimeout = timeout
+
+	if conf.Proxy != "" {
+		proxyURL, err := url.Parse(conf.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid download proxy `%s`: %v", conf.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if conf.CAFile != "" {
+		pem, err := os.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA file: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file `%s`", conf.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	return &Downloader{
+		client:  &http.Client{Transport: transport},
+		retries: retries,
+		timeout: timeout,
+		backoff: time.Second,
+	}, nil
+}
+
+// Client returns HTTP client configured with proxy, CA and timeouts of the downloader
+func (d *Downloader) Client() *http.Client {
+	return d.client
+}
+
+// Fetch reads the whole resource into memory, retrying on failures. Meant for small documents like release lists
+func (d *Downloader) Fetch(url string) ([]byte, error) {
+	if utils.IsLocalURL(url) {
+		return os.ReadFile(utils.LocalPath(url))
+	}
+
+	var body []byte
+	err := d.retry(url, func() error {
+		buf := new(bytes.Buffer)
+		if _, err := d.get(url, 0, buf); err != nil {
+			return err
+		}
+		body = buf.Bytes()
+		return nil
+	})
+	return body, err
+}
+
+// DownloadFile downloads url to dest reporting progress. Partial download is kept in `dest.part`,
+// so retries and subsequent runs resume it with HTTP Range requests
+func (d *Downloader) DownloadFile(url string, dest string) error {
+	if utils.IsLocalURL(url) {
+		return copyFile(utils.LocalPath(url), dest)
+	}
+
+	partPath := dest + ".part"
+	err := d.retry(url, func() error {
+		var offset int64
+		if info, err := os.Stat(partPath); err == nil {
+			offset = info.Size()
+		}
+
+		out, err := os.OpenFile(partPath, os.O_WRONLY|os.O_CREATE, 0o644)
+		if err != nil {
+			return permanentError{err}
+		}
+		defer out.Close()
+
+		progress := newProgress(url, offset)
+		total, err := d.get(url, offset, &writerAt{file: out, offset: offset, progress: progress})
+		progress.done(total, err)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return os.Rename(partPath, dest)
+}
+
+// retry runs fn until it succeeds, fails permanently or runs out of attempts, backing off exponentially
+func (d *Downloader) retry(url string, fn func() error) error {
+	backoff := d.backoff
+	var err error
+	for attempt := 0; attempt <= d.retries; attempt++ {
+		if attempt > 0 {
+			colors.WarnLog("Retrying %s in %s (attempt %d of %d): %v", url, backoff, attempt, d.retries, err)
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+
+		if err = fn(); err == nil {
+			return nil
+		}
+		var permanent permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+	}
+	return err
+}
+
+// get requests url starting at offset and copies the body to w. Returns the total size of the resource
+func (d *Downloader) get(url string, offset int64, w io.Writer) (int64, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, permanentError{err}
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+	case resp.StatusCode == http.StatusOK:
+		// Server ignored Range header and sends the whole file
+		if wa, ok := w.(*writerAt); ok && offset > 0 {
+			if err := wa.restart(); err != nil {
+				return 0, permanentError{err}
+			}
+		}
+		offset = 0
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// Partial file is already complete
+		return offset, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return 0, fmt.Errorf("error fetching %s: %v", url, resp.Status)
+	default:
+		return 0, permanentError{fmt.Errorf("error fetching %s: %v", url, resp.Status)}
+	}
+
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	if wa, ok := w.(*writerAt); ok {
+		wa.progress.setTotal(total)
+	}
+
+	// Abort stalled transfers: deadline is pushed forward on every read
+	timer := time.AfterFunc(d.timeout, cancel)
+	defer timer.Stop()
+	n, err := io.Copy(w, &stallReader{reader: resp.Body, timer: timer, timeout: d.timeout})
+	if err != nil {
+		if ctx.Err() != nil {
+			return total, fmt.Errorf("download of %s stalled for %s", url, d.timeout)
+		}
+		return total, err
+	}
+	return offset + n, nil
+}
+
+// stallReader resets the stall timer after every successful read
+type stallReader struct {
+	reader  io.Reader
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	n, err := s.reader.Read(p)
+	if n > 0 {
+		s.timer.Reset(s.timeout)
+	}
+	return n, err
+}
+
+// writerAt writes sequentially into file from offset and reports progress
+type writerAt struct {
+	file     *os.File
+	offset   int64
+	progress *progress
+}
+
+func (w *writerAt) Write(p []byte) (int, error) {
+	n, err := w.file.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	w.progress.add(int64(n))
+	return n, err
+}
+
+// restart empties the file when server sends full content, so an interrupted transfer does not leave
+// the new beginning followed by the stale tail of the previous one
+func (w *writerAt) restart() error {
+	w.offset = 0
+	w.progress.reset()
+	return w.file.Truncate(0)
+}
+
+// copyFile copies local file src to dest
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
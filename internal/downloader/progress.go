@@ -0,0 +1,86 @@
+package downloader
+
+import (
+	"autobuild-go/internal/utils"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	progressBarWidth = 30
+	progressInterval = 5 * time.Second
+)
+
+// progress renders a progress bar on terminals and periodic byte counts otherwise
+type progress struct {
+	name       string
+	current    int64
+	total      int64
+	tty        bool
+	lastReport time.Time
+}
+
+func newProgress(url string, offset int64) *progress {
+	info, err := os.Stdout.Stat()
+	return &progress{
+		name:       path.Base(url),
+		current:    offset,
+		total:      -1,
+		tty:        err == nil && info.Mode()&os.ModeCharDevice != 0,
+		lastReport: time.Now(),
+	}
+}
+
+func (p *progress) setTotal(total int64) {
+	p.total = total
+	if p.current > 0 {
+		fmt.Printf("\tResuming %s at %s\n", p.name, utils.HumanSize(p.current))
+	}
+}
+
+func (p *progress) reset() {
+	p.current = 0
+}
+
+func (p *progress) add(n int64) {
+	p.current += n
+	if p.tty {
+		p.render()
+		return
+	}
+	if time.Since(p.lastReport) >= progressInterval {
+		p.lastReport = time.Now()
+		if p.total > 0 {
+			fmt.Printf("\tDownloading %s: %s of %s\n", p.name, utils.HumanSize(p.current), utils.HumanSize(p.total))
+		} else {
+			fmt.Printf("\tDownloading %s: %s\n", p.name, utils.HumanSize(p.current))
+		}
+	}
+}
+
+// render redraws the progress bar in place
+func (p *progress) render() {
+	if p.total <= 0 {
+		fmt.Printf("\r\t%s %s", p.name, utils.HumanSize(p.current))
+		return
+	}
+	filled := int(float64(progressBarWidth) * float64(p.current) / float64(p.total))
+	if filled > progressBarWidth {
+		filled = progressBarWidth
+	}
+	fmt.Printf("\r\t%s [%s%s] %5.1f%% %s/%s", p.name, strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled),
+		100*float64(p.current)/float64(p.total), utils.HumanSize(p.current), utils.HumanSize(p.total))
+}
+
+// done finishes progress line
+func (p *progress) done(total int64, err error) {
+	if p.tty && p.current > 0 {
+		fmt.Println()
+	}
+	if err == nil && !p.tty && total > 0 {
+		fmt.Printf("\tDownloaded %s: %s\n", p.name, utils.HumanSize(total))
+	}
+}
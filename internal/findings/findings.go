@@ -0,0 +1,126 @@
+package findings
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Finding is a single issue reported by a code checker (gosec, linters...) in a tool independent form
+type Finding struct {
+	Tool       string `json:"tool"`
+	Rule       string `json:"rule"`
+	Severity   string `json:"severity"` // high, medium or low
+	Confidence string `json:"confidence,omitempty"`
+	File       string `json:"file"` // Slash separated path relative to module root
+	Line       int    `json:"line"`
+	Column     int    `json:"column"`
+	Message    string `json:"message"`
+	Code       string `json:"code,omitempty"`
+}
+
+// Fingerprint identifies finding independently of its line number, so baselines survive unrelated edits
+func (f Finding) Fingerprint() string {
+	location := strings.Join(strings.Fields(f.Code), " ")
+	if location == "" {
+		location = fmt.Sprintf("%d", f.Line)
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{f.Tool, f.Rule, f.File, location}, "|")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Baseline is a set of known findings which should not be reported again
+type Baseline map[string]bool
+
+// NewBaseline creates baseline of known findings
+func NewBaseline(known []Finding) Baseline {
+	baseline := Baseline{}
+	for _, finding := range known {
+		baseline[finding.Fingerprint()] = true
+	}
+	return baseline
+}
+
+// Filter splits findings into new ones and ones suppressed by baseline
+func (b Baseline) Filter(all []Finding) ([]Finding, []Finding) {
+	var kept, suppressed []Finding
+	for _, finding := range all {
+		if b[finding.Fingerprint()] {
+			suppressed = append(suppressed, finding)
+			continue
+		}
+		kept = append(kept, finding)
+	}
+	return kept, suppressed
+}
+
+// CountBySeverity returns human readable counts, e.g. "1 high, 2 medium"
+func CountBySeverity(all []Finding) string {
+	counts := map[string]int{}
+	for _, finding := range all {
+		counts[finding.Severity]++
+	}
+
+	var severities []string
+	for severity := range counts {
+		severities = append(severities, severity)
+	}
+	sort.Slice(severities, func(i, j int) bool {
+		return severityRank(severities[i]) > severityRank(severities[j])
+	})
+
+	var parts []string
+	for _, severity := range severities {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[severity], severity))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// severityRank orders severities from most to least important
+func severityRank(severity string) int {
+	switch severity {
+	case "high":
+		return 3
+	case "medium":
+		return 2
+	case "low":
+		return 1
+	}
+	return 0
+}
+
+// Collector gathers findings of all tools during the run, safe for concurrent use
+type Collector struct {
+	mutex sync.Mutex
+	tools map[string]bool
+	all   []Finding
+}
+
+// Add records findings of tool. Tool is remembered even without findings, so reports show it ran
+func (c *Collector) Add(tool string, found []Finding) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.tools == nil {
+		c.tools = map[string]bool{}
+	}
+	c.tools[tool] = true
+	c.all = append(c.all, found...)
+}
+
+// WriteSARIF writes all collected findings to a combined SARIF file. Nothing is written when no tool ran
+func (c *Collector) WriteSARIF(path string) (bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.tools) == 0 {
+		return false, nil
+	}
+
+	var tools []string
+	for tool := range c.tools {
+		tools = append(tools, tool)
+	}
+	return true, WriteSARIF(path, tools, c.all)
+}
@@ -0,0 +1,133 @@
+package findings
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+)
+
+// SARIF 2.1.0 document subset, enough for code scanning tools (e.g. GitHub code scanning) to consume findings
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules,omitempty"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine,omitempty"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// WriteSARIF writes findings as SARIF document with one run per tool. Every tool from tools gets a run,
+// even without findings, so consumers can tell a clean result from a missing one
+func WriteSARIF(path string, tools []string, all []Finding) error {
+	byTool := map[string][]Finding{}
+	for _, tool := range tools {
+		byTool[tool] = nil
+	}
+	for _, finding := range all {
+		byTool[finding.Tool] = append(byTool[finding.Tool], finding)
+	}
+
+	var names []string
+	for tool := range byTool {
+		names = append(names, tool)
+	}
+	sort.Strings(names)
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{},
+	}
+	for _, tool := range names {
+		log.Runs = append(log.Runs, toSARIFRun(tool, byTool[tool]))
+	}
+
+	contents, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, contents, 0o644)
+}
+
+func toSARIFRun(tool string, all []Finding) sarifRun {
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: tool}},
+		Results: []sarifResult{},
+	}
+
+	seenRules := map[string]bool{}
+	for _, finding := range all {
+		if !seenRules[finding.Rule] {
+			seenRules[finding.Rule] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: finding.Rule})
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:  finding.Rule,
+			Level:   sarifLevel(finding.Severity),
+			Message: sarifMessage{Text: finding.Message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: finding.File},
+					Region:           sarifRegion{StartLine: finding.Line, StartColumn: finding.Column},
+				},
+			}},
+		})
+	}
+	return run
+}
+
+// sarifLevel maps severity to SARIF result level
+func sarifLevel(severity string) string {
+	switch severity {
+	case "high":
+		return "error"
+	case "medium":
+		return "warning"
+	}
+	return "note"
+}
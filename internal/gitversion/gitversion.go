@@ -0,0 +1,85 @@
+package gitversion
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// describePattern matches `git describe --tags --long --abbrev=7` output, e.g. v1.2.3-5-gabc1234
+var describePattern = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)(-[0-9A-Za-z.-]+)?-(\d+)-g([0-9a-f]+)$`)
+
+// Derive computes semantic version of the git repository containing dir from its latest `vX.Y.Z` tag:
+//   - commit tagged v1.2.3 gives 1.2.3
+//   - 5 commits after v1.2.3 give snapshot of next patch 1.2.4-snapshot.5+abc1234
+//   - 5 commits after v1.3.0-rc.1 give 1.3.0-rc.1.snapshot.5+abc1234
+//   - repository without tags gives 0.0.0-snapshot.<commit count>+abc1234
+//
+// Uncommitted changes of tracked files add `-dirty` marker, e.g. 1.2.3-dirty or 1.2.4-snapshot.5+abc1234-dirty
+//
+// The `+abc1234` build metadata is not valid everywhere the version is used, every consumer sanitizes it
+// its own way, e.g. image tags replace `+` with `_` while package versions keep it
+func Derive(dir string) (string, error) {
+	git := func(args ...string) (string, error) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		var outBuf, errBuf bytes.Buffer
+		cmd.Stdout = &outBuf
+		cmd.Stderr = &errBuf
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(errBuf.String()))
+		}
+		return strings.TrimSpace(outBuf.String()), nil
+	}
+
+	hash, err := git("rev-parse", "--short=7", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	status, err := git("status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return "", err
+	}
+	dirty := status != ""
+
+	var version string
+	if describe, err := git("describe", "--tags", "--long", "--abbrev=7", "--match", "v[0-9]*.[0-9]*.[0-9]*"); err != nil {
+		// No version tag reachable from HEAD
+		count, err := git("rev-list", "--count", "HEAD")
+		if err != nil {
+			return "", err
+		}
+		version = fmt.Sprintf("0.0.0-snapshot.%s+%s", count, hash)
+	} else if version, err = fromDescribe(describe); err != nil {
+		return "", err
+	}
+
+	if dirty {
+		version += "-dirty"
+	}
+	return version, nil
+}
+
+// fromDescribe converts `git describe --long` output to semantic version
+func fromDescribe(describe string) (string, error) {
+	match := describePattern.FindStringSubmatch(describe)
+	if match == nil {
+		return "", fmt.Errorf("tag in `%s` is not a semantic version", describe)
+	}
+	major, minor, patch, preRelease, count, hash := match[1], match[2], match[3], match[4], match[5], match[6]
+
+	if count == "0" {
+		return major + "." + minor + "." + patch + preRelease, nil
+	}
+	if preRelease != "" {
+		return fmt.Sprintf("%s.%s.%s%s.snapshot.%s+%s", major, minor, patch, preRelease, count, hash), nil
+	}
+	nextPatch, err := strconv.Atoi(patch)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s.%s.%d-snapshot.%s+%s", major, minor, nextPatch+1, count, hash), nil
+}
@@ -4,11 +4,12 @@ import (
 	"archive/tar"
 	"archive/zip"
 	"autobuild-go/internal/colors"
+	"autobuild-go/internal/downloader"
 	"autobuild-go/internal/models"
+	"autobuild-go/internal/utils"
 	"compress/gzip"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -20,60 +21,209 @@ type GoInstaller struct {
 	projectPath    string
 	toolchainDir   string
 	selectedConfig models.SelectedConfig
+	toolchains     []models.GoToolchain
+	primary        models.GoToolchain
+	systemGo       []systemGo
+	systemGoFound  bool
+	downloader     *downloader.Downloader
 }
 
 // New creates a new instance of GoInstaller
-func New(projectPath string, cfg models.SelectedConfig) *GoInstaller {
+func New(projectPath string, cfg models.SelectedConfig, dl *downloader.Downloader) *GoInstaller {
 	toolchainDir := filepath.Join(cfg.Toolchain.Location, ".toolchain")
 
 	return &GoInstaller{
 		selectedConfig: cfg,
 		projectPath:    projectPath,
 		toolchainDir:   toolchainDir,
+		downloader:     dl,
 	}
 }
 
-// GoToolchainDir returns toolchian directory after updating it by EnsureGo() function
-func (g *GoInstaller) GoToolchainDir() string {
-	return g.toolchainDir
+// Primary returns the toolchain used to build artifacts
+func (g *GoInstaller) Primary() models.GoToolchain {
+	return g.primary
 }
 
-// EnsureGo checks if Go is installed, if not it installs the latest version
+// Toolchains returns all toolchains of the test matrix, in configuration order
+func (g *GoInstaller) Toolchains() []models.GoToolchain {
+	return g.toolchains
+}
+
+// EnsureGo checks if all configured Go versions are installed, installs the missing ones and selects the primary toolchain
 func (g *GoInstaller) EnsureGo() error {
-	goVersion := g.selectedConfig.Toolchain.Golang
-	if goVersion == "" || strings.ToLower(goVersion) == "latest" {
-		latestVersion, err := GetLatestGoVersion()
+	specs := g.selectedConfig.Toolchain.Golang
+	if len(specs) == 0 {
+		specs = models.GoVersions{"latest"}
+	}
+
+	primarySpec := g.selectedConfig.Toolchain.Primary
+	if primarySpec == "" {
+		primarySpec = specs[0]
+	}
+
+	resolved := map[string]string{}
+	for _, spec := range append(models.GoVersions{primarySpec}, specs...) {
+		if _, ok := resolved[spec]; ok {
+			continue
+		}
+		goVersion, err := g.resolveVersion(spec)
 		if err != nil {
-			return fmt.Errorf("error fetching latest Go version: %v", err)
+			return fmt.Errorf("error resolving Go version `%s`: %v", spec, err)
 		}
-		goVersion = latestVersion
-		g.selectedConfig.Toolchain.Golang = goVersion
+		resolved[spec] = goVersion
 	}
 
-	g.toolchainDir = filepath.Join(g.toolchainDir, g.selectedConfig.Toolchain.Golang)
+	g.toolchains = nil
+	seen := map[string]bool{}
+	for _, spec := range specs {
+		goVersion := resolved[spec]
+		if seen[goVersion] {
+			continue
+		}
+		seen[goVersion] = true
 
-	if g.isGoInstalled() {
-		colors.Success("Go %s already installed in %s%s%s", goVersion, colors.Blue, g.toolchainDir, colors.Reset)
-		return nil
+		toolchain, err := g.ensureVersion(goVersion)
+		if err != nil {
+			return err
+		}
+		g.toolchains = append(g.toolchains, toolchain)
+	}
+
+	primaryVersion := resolved[primarySpec]
+	if !seen[primaryVersion] {
+		toolchain, err := g.ensureVersion(primaryVersion)
+		if err != nil {
+			return err
+		}
+		g.primary = toolchain
+	} else {
+		for _, toolchain := range g.toolchains {
+			if toolchain.Version == primaryVersion {
+				g.primary = toolchain
+			}
+		}
+	}
+
+	for _, toolchain := range append([]models.GoToolchain{g.primary}, g.toolchains...) {
+		if err := markUsed(toolchain); err != nil {
+			colors.WarnLog("Cannot record last use of Go %s: %v", toolchain.Version, err)
+		}
+	}
+
+	if len(g.toolchains) > 1 {
+		var versions []string
+		for _, toolchain := range g.toolchains {
+			versions = append(versions, toolchain.Version)
+		}
+		colors.InfoLog("Test matrix: Go %s%s%s, primary toolchain: Go %s%s%s", colors.Blue, strings.Join(versions, ", "), colors.Reset, colors.Blue, g.primary.Version, colors.Reset)
+	}
+	return nil
+}
+
+// mirror returns base URL of Go archives and release list
+func (g *GoInstaller) mirror() string {
+	if g.selectedConfig.Mirrors.Toolchain != "" {
+		return g.selectedConfig.Mirrors.Toolchain
+	}
+	return DefaultMirror
+}
+
+// networkDisabled tells if offline mode forbids reaching the mirror
+func (g *GoInstaller) networkDisabled() bool {
+	return g.selectedConfig.Offline && !utils.IsLocalURL(g.mirror())
+}
+
+// resolveVersion resolves version spec against the mirror, or against installed toolchains in offline mode
+func (g *GoInstaller) resolveVersion(spec string) (string, error) {
+	if !g.networkDisabled() {
+		return ResolveGoVersion(g.downloader, spec, g.mirror())
+	}
+
+	entries, err := os.ReadDir(g.toolchainDir)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	var installed []string
+	for _, entry := range entries {
+		if entry.IsDir() && isGoInstalled(models.GoToolchain{Dir: filepath.Join(g.toolchainDir, entry.Name())}) {
+			installed = append(installed, entry.Name())
+		}
+	}
+
+	for _, system := range g.findSystemGo() {
+		installed = append(installed, system.Version)
+	}
+
+	version := matchVersion(normalizeSpec(spec), installed)
+	if version == "" {
+		return "", fmt.Errorf("no installed toolchain matches `%s` in %s and offline mode is enabled", spec, g.toolchainDir)
+	}
+	return version, nil
+}
+
+// ensureVersion installs a single Go version unless it is already present
+func (g *GoInstaller) ensureVersion(goVersion string) (models.GoToolchain, error) {
+	toolchain := models.GoToolchain{
+		Version: goVersion,
+		Dir:     filepath.Join(g.toolchainDir, goVersion),
+	}
+
+	if g.selectedConfig.Toolchain.PreferSystem {
+		systems := g.findSystemGo()
+		for _, system := range systems {
+			if system.Version == goVersion {
+				toolchain.Root = system.Root
+				colors.Success("Using system Go %s from %s%s%s (found on %s, prefer_system enabled and version matches)", goVersion, colors.Blue, system.Root, colors.Reset, system.Source)
+				return toolchain, nil
+			}
+		}
+		for _, system := range systems {
+			colors.InfoLog("System Go %s from %s (found on %s) does not match requested version %s", system.Version, system.Root, system.Source, goVersion)
+		}
+		if len(systems) == 0 {
+			colors.InfoLog("No system Go found on PATH or GOROOT")
+		}
+	}
+
+	if isGoInstalled(toolchain) {
+		colors.Success("Go %s already installed in %s%s%s", goVersion, colors.Blue, toolchain.Dir, colors.Reset)
+		return toolchain, nil
+	}
+	if g.networkDisabled() {
+		return toolchain, fmt.Errorf("Go %s is not installed and offline mode is enabled", goVersion)
 	}
 	colors.Icon(colors.Yellow, "\u226b", "Go is not installed. Installing '%s' version...", goVersion)
 
-	if err := g.downloadAndInstallGo(goVersion); err != nil {
-		return fmt.Errorf("error downloading and installing Go: %v", err)
+	if err := g.downloadAndInstallGo(goVersion, toolchain.Dir); err != nil {
+		return toolchain, fmt.Errorf("error downloading and installing Go: %v", err)
 	}
 
 	colors.Success("Go %s%s%s installed successfully!", colors.Blue, goVersion, colors.Reset)
-	return nil
+	return toolchain, nil
+}
+
+// findSystemGo returns Go installations found on PATH or GOROOT when `prefer_system` is enabled. Result is cached
+func (g *GoInstaller) findSystemGo() []systemGo {
+	if !g.selectedConfig.Toolchain.PreferSystem {
+		return nil
+	}
+	if !g.systemGoFound {
+		g.systemGo = findSystemGo()
+		g.systemGoFound = true
+	}
+	return g.systemGo
 }
 
 // isGoInstalled checks if Go is already installed
-func (g *GoInstaller) isGoInstalled() bool {
-	_, err := os.Stat(filepath.Join(g.toolchainDir, "go"))
+func isGoInstalled(toolchain models.GoToolchain) bool {
+	_, err := os.Stat(toolchain.GoRoot())
 	return !os.IsNotExist(err)
 }
 
-// downloadAndInstallGo downloads and installs the latest Go version
-func (g *GoInstaller) downloadAndInstallGo(version string) error {
+// downloadAndInstallGo downloads given Go version from mirror and installs it into the toolchain directory
+func (g *GoInstaller) downloadAndInstallGo(version string, toolchainDir string) error {
 	osArch := fmt.Sprintf("%s-%s", runtime.GOOS, runtime.GOARCH)
 	var archiveExt string
 	var goFilename string
@@ -86,22 +236,22 @@ func (g *GoInstaller) downloadAndInstallGo(version string) error {
 		goFilename = fmt.Sprintf("go%s.%s.%s", version, osArch, archiveExt)
 	}
 
-	downloadURL := fmt.Sprintf("https://golang.org/dl/%s", goFilename)
+	downloadURL := fmt.Sprintf("%s/%s", strings.TrimSuffix(g.mirror(), "/"), goFilename)
 
-	// Download the archive
+	// Download the archive, partial downloads are resumed on next attempt
 	archiveFilePath := filepath.Join(os.TempDir(), goFilename)
-	if err := downloadFile(downloadURL, archiveFilePath); err != nil {
+	if err := g.downloader.DownloadFile(downloadURL, archiveFilePath); err != nil {
 		return fmt.Errorf("error downloading Go archive: %v", err)
 	}
 	defer os.Remove(archiveFilePath) // Cleanup
 
 	// Extract the file
 	if runtime.GOOS == "windows" {
-		if err := unzip(archiveFilePath, g.toolchainDir); err != nil {
+		if err := unzip(archiveFilePath, toolchainDir); err != nil {
 			return fmt.Errorf("error unzipping Go archive: %v", err)
 		}
 	} else {
-		if err := untarGz(archiveFilePath, g.toolchainDir); err != nil {
+		if err := untarGz(archiveFilePath, toolchainDir); err != nil {
 			return fmt.Errorf("error untarring Go archive: %v", err)
 		}
 	}
@@ -109,28 +259,6 @@ func (g *GoInstaller) downloadAndInstallGo(version string) error {
 	return nil
 }
 
-// downloadFile downloads a file from a given URL to a local path
-func downloadFile(url string, dest string) error {
-	out, err := os.Create(dest)
-	if err != nil {
-		return err
-	}
-	defer out.Close()
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("error downloading file: %v", resp.Status)
-	}
-
-	_, err = io.Copy(out, resp.Body)
-	return err
-}
-
 // unzip extracts a zip file to the destination directory (for Windows)
 func unzip(src, dest string) error {
 	r, err := zip.OpenReader(src)
@@ -0,0 +1,145 @@
+package golanginstaller
+
+import (
+	"autobuild-go/internal/models"
+	"autobuild-go/internal/utils"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// lastUsedFile is a marker in toolchain directory holding the time toolchain was last used by EnsureGo
+const lastUsedFile = ".last-used"
+
+// InstalledToolchain describes toolchain present in toolchain directory
+type InstalledToolchain struct {
+	models.GoToolchain
+	Size     int64     // Total size in bytes, including GOPATH (modules and tools)
+	LastUsed time.Time // Last time toolchain was selected by EnsureGo
+}
+
+// ListInstalled returns toolchains installed in the toolchain directory, newest version first
+func (g *GoInstaller) ListInstalled() ([]InstalledToolchain, error) {
+	entries, err := os.ReadDir(g.toolchainDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var installed []InstalledToolchain
+	for _, entry := range entries {
+		toolchain := models.GoToolchain{Version: entry.Name(), Dir: filepath.Join(g.toolchainDir, entry.Name())}
+		if !entry.IsDir() || !isGoInstalled(toolchain) {
+			continue
+		}
+		installed = append(installed, InstalledToolchain{
+			GoToolchain: toolchain,
+			Size:        dirSize(toolchain.Dir),
+			LastUsed:    lastUsed(toolchain),
+		})
+	}
+
+	sort.Slice(installed, func(i, j int) bool {
+		return utils.CompareVersions(installed[i].Version, installed[j].Version) > 0
+	})
+	return installed, nil
+}
+
+// Install resolves version spec and installs it unless already present
+func (g *GoInstaller) Install(spec string) (models.GoToolchain, error) {
+	goVersion, err := g.resolveVersion(spec)
+	if err != nil {
+		return models.GoToolchain{}, err
+	}
+	return g.ensureVersion(goVersion)
+}
+
+// Use installs toolchain like Install and records it as used
+func (g *GoInstaller) Use(spec string) (models.GoToolchain, error) {
+	toolchain, err := g.Install(spec)
+	if err != nil {
+		return toolchain, err
+	}
+	return toolchain, markUsed(toolchain)
+}
+
+// Prune removes all but keep most recently used toolchains together with their GOPATH and tool caches
+func (g *GoInstaller) Prune(keep int) ([]InstalledToolchain, error) {
+	installed, err := g.ListInstalled()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(installed, func(i, j int) bool {
+		return installed[i].LastUsed.After(installed[j].LastUsed)
+	})
+
+	if keep < 0 {
+		keep = 0
+	}
+	if len(installed) <= keep {
+		return nil, nil
+	}
+
+	removed := installed[keep:]
+	for _, toolchain := range removed {
+		if err := removeAll(toolchain.Dir); err != nil {
+			return nil, err
+		}
+	}
+	return removed, nil
+}
+
+// markUsed records current time as last use of toolchain
+func markUsed(toolchain models.GoToolchain) error {
+	if err := os.MkdirAll(toolchain.Dir, os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(toolchain.Dir, lastUsedFile), []byte(time.Now().UTC().Format(time.RFC3339)), 0o644)
+}
+
+// lastUsed reads last use time of toolchain, falling back to directory modification time
+func lastUsed(toolchain models.GoToolchain) time.Time {
+	if contents, err := os.ReadFile(filepath.Join(toolchain.Dir, lastUsedFile)); err == nil {
+		if t, err := time.Parse(time.RFC3339, strings.TrimSpace(string(contents))); err == nil {
+			return t
+		}
+	}
+	if info, err := os.Stat(toolchain.Dir); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
+
+// dirSize sums sizes of all regular files below dir
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// removeAll removes dir recursively. Go module cache is read-only, so directories are made writable first
+func removeAll(dir string) error {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			os.Chmod(path, 0o755)
+		}
+		return nil
+	})
+	return os.RemoveAll(dir)
+}
@@ -0,0 +1,65 @@
+package golanginstaller
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// systemGo describes Go installation found outside of the managed toolchain directory
+type systemGo struct {
+	Version string // e.g. 1.22.5
+	Root    string // GOROOT reported by `go env GOROOT`
+	Source  string // Where it was found, PATH or GOROOT
+}
+
+// findSystemGo looks for Go on PATH and in GOROOT and asks each candidate for its version with `go env GOVERSION GOROOT`
+func findSystemGo() []systemGo {
+	goExec := "go"
+	if runtime.GOOS == "windows" {
+		goExec = "go.exe"
+	}
+
+	candidates := map[string]string{}
+	var order []string
+	if p, err := exec.LookPath(goExec); err == nil {
+		candidates[p] = "PATH"
+		order = append(order, p)
+	}
+	if goroot := os.Getenv("GOROOT"); goroot != "" {
+		p := filepath.Join(goroot, "bin", goExec)
+		if _, ok := candidates[p]; !ok {
+			candidates[p] = "GOROOT"
+			order = append(order, p)
+		}
+	}
+
+	var found []systemGo
+	seenRoots := map[string]bool{}
+	for _, p := range order {
+		cmd := exec.Command(p, "env", "GOVERSION", "GOROOT")
+		// Do not let GOTOOLCHAIN switch to another toolchain while asking for version
+		cmd.Env = append(os.Environ(), "GOTOOLCHAIN=local")
+		var out bytes.Buffer
+		cmd.Stdout = &out
+		if err := cmd.Run(); err != nil {
+			continue
+		}
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if len(lines) < 2 {
+			continue
+		}
+		version := extractVersionNumber(strings.TrimSpace(lines[0]))
+		root := strings.TrimSpace(lines[1])
+		if version == "" || root == "" || seenRoots[root] {
+			continue
+		}
+		seenRoots[root] = true
+		found = append(found, systemGo{Version: version, Root: root, Source: candidates[p]})
+	}
+	return found
+}
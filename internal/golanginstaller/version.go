@@ -1,39 +1,121 @@
 package golanginstaller
 
 import (
+	"autobuild-go/internal/downloader"
+	"autobuild-go/internal/utils"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"regexp"
+	"strings"
 )
 
-// GetLatestGoVersion fetches the latest stable Go version
-func GetLatestGoVersion() (string, error) {
-	resp, err := http.Get("https://golang.org/dl/?mode=json")
+// DefaultMirror is the upstream location of Go archives and release list
+const DefaultMirror = "https://golang.org/dl"
+
+// goRelease represents a single entry of https://golang.org/dl/?mode=json
+type goRelease struct {
+	Version string `json:"version"`
+	Stable  bool   `json:"stable"`
+}
+
+// fetchGoReleases fetches the list of Go releases from mirror. When includeAll is false only the current stable releases are returned.
+// Local mirrors (directories) serve the list from `index.json` file
+func fetchGoReleases(dl *downloader.Downloader, mirror string, includeAll bool) ([]goRelease, error) {
+	url := strings.TrimSuffix(mirror, "/") + "/?mode=json"
+	if includeAll {
+		url += "&include=all"
+	}
+	if utils.IsLocalURL(mirror) {
+		url = strings.TrimSuffix(mirror, "/") + "/index.json"
+	}
+
+	body, err := dl.Fetch(url)
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("error fetching Go versions: %v", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("error fetching Go versions: %v", resp.Status)
+	var releases []goRelease
+	if err := json.Unmarshal(body, &releases); err != nil {
+		return nil, err
 	}
+	return releases, nil
+}
 
-	var versions []map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&versions); err != nil {
+// GetLatestGoVersion fetches the latest stable Go version from mirror
+func GetLatestGoVersion(dl *downloader.Downloader, mirror string) (string, error) {
+	releases, err := fetchGoReleases(dl, mirror, false)
+	if err != nil {
 		return "", err
 	}
 
-	for _, version := range versions {
-		if stable, ok := version["stable"].(bool); ok && stable {
-			versionStr := version["version"].(string)
-			return extractVersionNumber(versionStr), nil
+	for _, release := range releases {
+		if release.Stable {
+			return extractVersionNumber(release.Version), nil
 		}
 	}
 
 	return "", fmt.Errorf("no stable version found")
 }
 
+// ResolveGoVersion turns a version spec into a concrete Go version using release list from mirror:
+// "" or "latest" -> latest stable, "1.21.x" -> newest stable 1.21 patch release, anything else is returned as is
+func ResolveGoVersion(dl *downloader.Downloader, spec string, mirror string) (string, error) {
+	spec = normalizeSpec(spec)
+	if spec == "latest" {
+		return GetLatestGoVersion(dl, mirror)
+	}
+	if !strings.HasSuffix(spec, ".x") {
+		return spec, nil
+	}
+
+	releases, err := fetchGoReleases(dl, mirror, true)
+	if err != nil {
+		return "", err
+	}
+
+	var versions []string
+	for _, release := range releases {
+		if release.Stable {
+			versions = append(versions, extractVersionNumber(release.Version))
+		}
+	}
+
+	best := matchVersion(spec, versions)
+	if best == "" {
+		return "", fmt.Errorf("no stable Go release matching `%s` found", spec)
+	}
+	return best, nil
+}
+
+// normalizeSpec lowercases version spec, strips `go` prefix and maps empty spec to "latest"
+func normalizeSpec(spec string) string {
+	spec = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(spec)), "go")
+	if spec == "" {
+		return "latest"
+	}
+	return spec
+}
+
+// matchVersion returns the newest of versions matching spec ("latest", "1.21.x" or exact version), empty string if none matches
+func matchVersion(spec string, versions []string) string {
+	best := ""
+	for _, version := range versions {
+		switch {
+		case spec == "latest":
+		case strings.HasSuffix(spec, ".x"):
+			if !strings.HasPrefix(version, strings.TrimSuffix(spec, "x")) {
+				continue
+			}
+		case version != spec:
+			continue
+		}
+		if best == "" || utils.CompareVersions(version, best) > 0 {
+			best = version
+		}
+	}
+	return best
+}
+
 // extractVersionNumber extracts the version number from the string (e.g., "go1.17.2" -> "1.17.2")
 func extractVersionNumber(version string) string {
 	re := regexp.MustCompile(`go([0-9.]+)`)
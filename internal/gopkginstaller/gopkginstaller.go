@@ -2,48 +2,189 @@ package gopkginstaller
 
 import (
 	"autobuild-go/internal/colors"
+	"autobuild-go/internal/models"
 	"bytes"
+	"debug/buildinfo"
 	"fmt"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
+	"strings"
+	"sync"
 )
 
+// DefaultTools are installed for stages which need them, unless `tools` section of `autobuild.yaml` overrides them
+var DefaultTools = map[string]string{
+	"gosec":         "github.com/securego/gosec/v2/cmd/gosec@v2.21.4",
+	"govulncheck":   "golang.org/x/vuln/cmd/govulncheck@v1.1.3",
+	"staticcheck":   "honnef.co/go/tools/cmd/staticcheck@v0.5.1",
+	"golangci-lint": "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.62.0",
+}
+
+// stageTools maps stages to tools they run
+var stageTools = map[string]string{
+	"gosec": "gosec",
+	"vuln":  "govulncheck",
+}
+
+// maxParallelInstalls bounds number of concurrent `go install` processes
+const maxParallelInstalls = 4
+
+// ConfiguredTools returns tools from configuration together with default tools of enabled stages which are not configured
+func ConfiguredTools(conf models.SelectedConfig) map[string]string {
+	tools := map[string]string{}
+	for name, pkg := range conf.Tools {
+		tools[name] = pkg
+	}
+	for _, stage := range conf.Profile.Stages {
+		name := stageTools[stage]
+		if stage == "lint" {
+			name = conf.Profile.Lint.LinterName()
+		}
+		if _, configured := tools[name]; !configured && DefaultTools[name] != "" {
+			tools[name] = DefaultTools[name]
+		}
+	}
+	return tools
+}
+
+var majorVersionSuffix = regexp.MustCompile(`^v[0-9]+$`)
+
+// ToolStatus describes configured tool and its installation in the tool cache
+type ToolStatus struct {
+	Name      string // Tool name from configuration
+	Package   string // Package path without version
+	Requested string // Requested version, `latest` when not pinned
+	Installed string // Version of installed binary, empty when not installed
+	GoVersion string // Go version installed binary was built with
+	Path      string // Binary location in the tool cache
+	UpToDate  bool
+}
+
 type pkgInstaller struct {
-	packages   map[string]string
-	goexecPath string
-	gorootPath string
-	gopathPath string
+	packages  map[string]string
+	toolchain models.GoToolchain
+	extraEnv  []string
 }
 
+// Install installs, in parallel, every package which is missing from the tool cache or was built from another version.
+// Installation failures are reported with captured `go install` output and returned as error
 func (p *pkgInstaller) Install() error {
-	suffix := ""
-	if runtime.GOOS == "windows" {
-		suffix = ".exe"
+	return p.install(false)
+}
+
+// Update works like Install, but also reinstalls tools which are not pinned to a version (`@latest`)
+func (p *pkgInstaller) Update() error {
+	return p.install(true)
+}
+
+// List returns status of all configured tools sorted by name
+func (p *pkgInstaller) List() []ToolStatus {
+	var statuses []ToolStatus
+	for _, name := range p.names() {
+		statuses = append(statuses, p.status(name))
 	}
-	for k, v := range p.packages {
-		fmt.Printf("\t%s>>%sChecking package %s%s%s... ", colors.Green, colors.Reset, colors.Blue, k, colors.Reset)
+	return statuses
+}
 
-		if _, err := os.Lstat(filepath.Join(p.gopathPath, "bin", fmt.Sprintf("%s%s", k, suffix))); err != nil {
-			if p.installPkg(v) != nil {
-				fmt.Println(colors.Red, "[fail]", colors.Reset)
-				continue
-			}
-			fmt.Println(colors.Green, "[ok]", colors.Reset)
+func (p *pkgInstaller) install(refreshLatest bool) error {
+	var (
+		wg      sync.WaitGroup
+		mutex   sync.Mutex
+		failed  []string
+		limiter = make(chan struct{}, maxParallelInstalls)
+	)
+
+	for _, name := range p.names() {
+		status := p.status(name)
+		if status.UpToDate && !(refreshLatest && status.Requested == "latest") {
+			colors.Success("Package "+colors.Blue+"%s"+colors.Reset+" %s (go%s) already installed", name, status.Installed, p.toolchain.Version)
 			continue
 		}
-		fmt.Println(colors.Green, "[ok]", colors.Reset)
+
+		wg.Add(1)
+		go func(status ToolStatus) {
+			defer wg.Done()
+			limiter <- struct{}{}
+			defer func() { <-limiter }()
+
+			colors.Icon(colors.Yellow, "\u226b", "Installing package "+colors.Blue+"%s"+colors.Reset+" %s", status.Name, status.Requested)
+			output, err := p.installPkg(status)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				colors.ErrLog("Cannot install %s (%s@%s): %v", status.Name, status.Package, status.Requested, err)
+				for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+					fmt.Printf("\t\t%s\n", line)
+				}
+				failed = append(failed, status.Name)
+				return
+			}
+			colors.Success("Installed package "+colors.Blue+"%s"+colors.Reset+" %s to %s", status.Name, status.Requested, status.Path)
+		}(status)
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("failed to install: %s", strings.Join(failed, ", "))
 	}
 	return nil
 }
 
-func (p *pkgInstaller) installPkg(v string) error {
+// names returns configured tool names in sorted order
+func (p *pkgInstaller) names() []string {
+	names := make([]string, 0, len(p.packages))
+	for name := range p.packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// status reads build info of cached binary and compares it with requested package, version and Go version.
+// `@latest` is satisfied by any installed version
+func (p *pkgInstaller) status(name string) ToolStatus {
+	pkg := p.packages[name]
+	pkgPath, version := splitPackage(pkg)
+	if version == "" {
+		version = "latest"
+	}
+
+	status := ToolStatus{
+		Name:      name,
+		Package:   pkgPath,
+		Requested: version,
+		Path:      ToolPath(p.toolchain, name, pkg),
+	}
+
+	info, err := buildinfo.ReadFile(status.Path)
+	if err != nil {
+		return status
+	}
+	status.Installed = info.Main.Version
+	// Only the version field is compared, e.g. of `go1.22.5 X:boringcrypto`
+	goVersion, _, _ := strings.Cut(info.GoVersion, " ")
+	status.GoVersion = strings.TrimPrefix(goVersion, "go")
+	status.UpToDate = info.Path == pkgPath &&
+		status.GoVersion == p.toolchain.Version &&
+		(version == "latest" || info.Main.Version == version)
+	return status
+}
+
+func (p *pkgInstaller) installPkg(status ToolStatus) (string, error) {
 	envVariables := os.Environ()
-	envVariables = append(envVariables, "GOPATH="+p.gopathPath, "GOROOT="+p.gorootPath)
-	envVariables = append(envVariables, "PATH="+fmt.Sprintf("%s%s%s", filepath.Join(p.gopathPath, "bin"), string(os.PathListSeparator), os.Getenv("PATH")))
+	envVariables = append(envVariables, "GOPATH="+p.toolchain.GoPath(), "GOROOT="+p.toolchain.GoRoot())
+	envVariables = append(envVariables, "GOBIN="+filepath.Dir(status.Path))
+	envVariables = append(envVariables, "PATH="+fmt.Sprintf("%s%s%s", filepath.Join(p.toolchain.GoRoot(), "bin"), string(os.PathListSeparator), os.Getenv("PATH")))
+	envVariables = append(envVariables, p.extraEnv...)
 
-	execCmd := exec.Command(p.goexecPath, "install", v)
+	execCmd := exec.Command(p.toolchain.GoExec(), "install", status.Package+"@"+status.Requested)
 	execCmd.Env = envVariables
 
 	execBuff := new(bytes.Buffer)
@@ -51,20 +192,47 @@ func (p *pkgInstaller) installPkg(v string) error {
 	execCmd.Stderr = execBuff
 
 	if err := execCmd.Run(); err != nil {
-		return err
+		return execBuff.String(), err
 	}
-	return nil
+	return execBuff.String(), nil
+}
+
+// splitPackage splits `pkg@version` into package path and version
+func splitPackage(pkg string) (string, string) {
+	if i := strings.LastIndex(pkg, "@"); i >= 0 {
+		return pkg[:i], pkg[i+1:]
+	}
+	return pkg, ""
 }
 
-func New(toolchainDir string, packages map[string]string) *pkgInstaller {
-	goExec := "go"
+// ToolPath returns location of the tool binary in the tool cache of toolchain. Cache is keyed by tool version
+// (`<toolchain>/tools/<name>@<version>`) and, as every toolchain has its own cache, by Go version
+func ToolPath(toolchain models.GoToolchain, name string, pkg string) string {
+	_, version := splitPackage(pkg)
+	if version == "" {
+		version = "latest"
+	}
+	return filepath.Join(toolchain.Dir, "tools", name+"@"+version, BinaryName(pkg))
+}
+
+// BinaryName returns name of the binary `go install` creates for the package, skipping major version suffix (e.g. /v2)
+func BinaryName(pkg string) string {
+	pkgPath, _ := splitPackage(pkg)
+	name := path.Base(pkgPath)
+	if majorVersionSuffix.MatchString(name) {
+		name = path.Base(path.Dir(pkgPath))
+	}
 	if runtime.GOOS == "windows" {
-		goExec = "go.exe"
+		name += ".exe"
 	}
+	return name
+}
+
+// New creates package installer for given toolchain. extraEnv is appended to the environment of `go install`
+func New(toolchain models.GoToolchain, packages map[string]string, extraEnv []string) *pkgInstaller {
 	return &pkgInstaller{
-		packages:   packages,
-		goexecPath: filepath.Join(toolchainDir, "go", "bin", goExec),
-		gorootPath: filepath.Join(toolchainDir, "go"),
-		gopathPath: filepath.Join(toolchainDir, "gopath"),
+		packages:  packages,
+		toolchain: toolchain,
+		extraEnv:  extraEnv,
 	}
 }
@@ -1,29 +1,237 @@
 package models
 
-import "path/filepath"
+import (
+	"path/filepath"
+	"strings"
+)
 
 // Profile represents the structure of each profile in the YAML
 type Profile struct {
-	OS     map[string][]string `yaml:"os"`     // Operating systems with architectures
-	Stages []string            `yaml:"stages"` // List of stages
+	OS           map[string][]string     `yaml:"os"`           // Operating systems with architectures
+	Targets      []Target                `yaml:"targets"`      // Additional targets with microarchitecture variants
+	Variants     map[string]BuildVariant `yaml:"variants"`     // Named build variants, each target is built once per variant
+	Inject       map[string]string       `yaml:"inject"`       // Variables set with `-X`, `<package path>.<name>` to value template
+	Version      string                  `yaml:"version"`      // Release version, `git` derives it from tags. Artifact names carry it
+	Reproducible bool                    `yaml:"reproducible"` // Build with -trimpath, without VCS stamp, fixed build date and clean environment
+	Stages       []string                `yaml:"stages"`       // List of stages
+	Gosec        Gosec                   `yaml:"gosec"`        // Settings of `gosec` stage
+	Vuln         Vuln                    `yaml:"vuln"`         // Settings of `vuln` stage
+	Lint         Lint                    `yaml:"lint"`         // Settings of `lint` stage
+	Test         Test                    `yaml:"test"`         // Settings of `test` stage
+	Archive      Archive                 `yaml:"archive"`      // Settings of `archive` stage
+	Package      Package                 `yaml:"package"`      // Settings of `deb` and `rpm` stages
+	Image        Image                   `yaml:"image"`        // Settings of `oci` stage
+}
+
+// BuildVariant configures build of a named variant (edition) of applications
+type BuildVariant struct {
+	Tags       []string          `yaml:"tags"`        // Build tags (-tags)
+	Ldflags    string            `yaml:"ldflags"`     // Linker flags (-ldflags), e.g. `-s -w -X main.edition=enterprise`
+	Gcflags    string            `yaml:"gcflags"`     // Compiler flags (-gcflags)
+	CGOEnabled *bool             `yaml:"cgo_enabled"` // CGO_ENABLED, environment default when not set
+	Trimpath   bool              `yaml:"trimpath"`    // Remove file system paths from binaries (-trimpath)
+	Env        map[string]string `yaml:"env"`         // Environment overrides
+}
+
+// Target is a build target with optional architecture variant. Only the variant field matching Arch may be set
+type Target struct {
+	OS      string `yaml:"os"`
+	Arch    string `yaml:"arch"`
+	GOARM   string `yaml:"goarm"`   // arm: 5, 6 or 7, optionally with ,softfloat or ,hardfloat (Go 1.22+)
+	GOAMD64 string `yaml:"goamd64"` // amd64: v1 to v4
+	GOARM64 string `yaml:"goarm64"` // arm64: v8.0 to v9.5, optionally with ,lse and ,crypto (Go 1.23+)
+	GO386   string `yaml:"go386"`   // 386: sse2 or softfloat
+	GOMIPS  string `yaml:"gomips"`  // mips, mipsle, mips64, mips64le: hardfloat or softfloat
+	GOPPC64 string `yaml:"goppc64"` // ppc64, ppc64le: power8, power9 or power10 (Go 1.21+)
+}
+
+// Archive configures `archive` stage
+type Archive struct {
+	Name   string   `yaml:"name"`   // Archive name template without extension, e.g. `{{.App}}_{{.Version}}_{{.OS}}_{{.Arch}}`
+	Format string   `yaml:"format"` // tar.gz or zip, zip for windows and tar.gz for other targets when not set
+	Files  []string `yaml:"files"`  // Globs of files bundled with the binary, relative to module root, e.g. LICENSE, configs/*.yaml
+	Wrap   *bool    `yaml:"wrap"`   // Put files in a directory named like the archive, true when not set
+}
+
+// Package configures `deb` and `rpm` stages. Paths of sources are relative to module root
+type Package struct {
+	Name        string            `yaml:"name"`         // Package name, app name (with build variant) when not set
+	Description string            `yaml:"description"`  // First line is the summary
+	Maintainer  string            `yaml:"maintainer"`   // e.g. `Ops Team <ops@example.com>`
+	Vendor      string            `yaml:"vendor"`       // Vendor (RPM only)
+	Homepage    string            `yaml:"homepage"`     // Project URL
+	License     string            `yaml:"license"`      // License (RPM only)
+	Release     string            `yaml:"release"`      // Package release (RPM Release, Debian revision), 1 when not set
+	BinDir      string            `yaml:"bin_dir"`      // Directory of installed binary, /usr/bin when not set
+	Files       map[string]string `yaml:"files"`        // Install path to source file or directory
+	ConfigFiles map[string]string `yaml:"config_files"` // Install path to source of configuration file kept on upgrade
+	Systemd     []string          `yaml:"systemd"`      // systemd unit files installed to the unit directory of the distribution
+	Scripts     PackageScripts    `yaml:"scripts"`      // Maintainer scripts
+	Depends     []string          `yaml:"depends"`      // Dependencies of both formats, e.g. `ca-certificates` or `tzdata >= 2023`
+	Deb         PackageFormat     `yaml:"deb"`          // Debian specific settings
+	RPM         PackageFormat     `yaml:"rpm"`          // RPM specific settings
+}
+
+// PackageScripts are sources of shell scripts run by package manager
+type PackageScripts struct {
+	PreInstall  string `yaml:"pre_install"`
+	PostInstall string `yaml:"post_install"`
+	PreRemove   string `yaml:"pre_remove"`
+	PostRemove  string `yaml:"post_remove"`
+}
+
+// PackageFormat configures package format specific settings
+type PackageFormat struct {
+	Depends []string `yaml:"depends"` // Dependencies added to common dependencies, as package names differ between distributions
+}
+
+// Image configures `oci` stage. Paths of sources are relative to module root
+type Image struct {
+	Name       string            `yaml:"name"`       // Image name, e.g. registry.example.com/team/app. App name (with build variant) when not set
+	Tags       []string          `yaml:"tags"`       // Tag templates with .Version, the version (or latest without version) when not set
+	Base       string            `yaml:"base"`       // `scratch` (default) or base image tarball from `docker save` or with OCI layout
+	BinDir     string            `yaml:"bin_dir"`    // Directory of the binary in the image, /usr/local/bin when not set
+	Entrypoint []string          `yaml:"entrypoint"` // The binary when not set
+	Cmd        []string          `yaml:"cmd"`        // Default arguments
+	Env        []string          `yaml:"env"`        // Environment variables, KEY=value
+	WorkDir    string            `yaml:"workdir"`    // Working directory
+	User       string            `yaml:"user"`       // User (and group) running the entrypoint, e.g. 65532:65532
+	Ports      []string          `yaml:"ports"`      // Exposed ports, e.g. 8080 or 53/udp
+	Labels     map[string]string `yaml:"labels"`     // Image labels
+	Files      map[string]string `yaml:"files"`      // Image path to source file or directory
+}
+
+// Test configures `test` stage
+type Test struct {
+	Race    bool     `yaml:"race"`    // Enable race detector (-race)
+	Shuffle string   `yaml:"shuffle"` // Randomize test order: on, off or a seed (-shuffle)
+	Count   int      `yaml:"count"`   // Run each test N times (-count)
+	Timeout string   `yaml:"timeout"` // Test binary timeout, e.g. 10m (-timeout)
+	Tags    []string `yaml:"tags"`    // Build tags (-tags)
+	Run     string   `yaml:"run"`     // Run only tests matching pattern (-run)
+	Skip    string   `yaml:"skip"`    // Skip tests matching pattern (-skip)
+	Short   bool     `yaml:"short"`   // Tell long-running tests to shorten their run time (-short)
+	Args    []string `yaml:"args"`    // Extra `go test` arguments
+	Retries int      `yaml:"retries"` // Rerun failed tests up to N times, tests passing on retry are reported as flaky
+	History string   `yaml:"history"` // Flaky test history file, relative to scanned directory, defaults to .build/test-history.json
+}
+
+// Lint configures `lint` stage
+type Lint struct {
+	Linter  string   `yaml:"linter"`   // staticcheck (default) or golangci-lint
+	Args    []string `yaml:"args"`     // Extra linter arguments
+	NewFrom string   `yaml:"new_from"` // Git ref, only issues on lines changed since merge base with it are reported
+}
+
+// LinterName returns configured linter, staticcheck by default
+func (l Lint) LinterName() string {
+	if l.Linter == "" {
+		return "staticcheck"
+	}
+	return l.Linter
+}
+
+// Vuln configures `vuln` stage
+type Vuln struct {
+	DB string `yaml:"db"` // Vulnerability database URL or local directory (required in offline mode)
+}
+
+// Gosec configures `gosec` stage
+type Gosec struct {
+	Severity     string   `yaml:"severity"`      // Minimum severity reported: low, medium or high
+	Confidence   string   `yaml:"confidence"`    // Minimum confidence reported: low, medium or high
+	ExcludeRules []string `yaml:"exclude_rules"` // Rules not checked, e.g. G104
+	ExcludeDirs  []string `yaml:"exclude_dirs"`  // Directories not scanned
+	ConfigFile   string   `yaml:"config_file"`   // gosec configuration file, relative to module root
+	Baseline     string   `yaml:"baseline"`      // gosec JSON report with known findings, relative to module root
+}
+
+// GoVersions is a list of Go versions. In YAML it accepts either a single version or a list
+type GoVersions []string
+
+// UnmarshalYAML allows `golang: latest` as well as `golang: [1.21.x, latest]`
+func (v *GoVersions) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var single string
+	if err := unmarshal(&single); err == nil {
+		*v = GoVersions{single}
+		return nil
+	}
+
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+	*v = list
+	return nil
 }
 
 // Toolchain represents the static toolchain configuration
 type Toolchain struct {
-	Golang   string `yaml:"golang"`
-	Location string `yaml:"location"`
+	Golang       GoVersions `yaml:"golang"`        // Go versions to test with (e.g. 1.21.x, 1.22.3, latest)
+	Primary      string     `yaml:"primary"`       // Go version used to build artifacts, defaults to first of `golang`
+	Location     string     `yaml:"location"`      // Directory holding `.toolchain`
+	PreferSystem bool       `yaml:"prefer_system"` // Reuse Go found on PATH or GOROOT when its version matches
+}
+
+// Mirrors replaces upstream services with a local directory (path or file:// URL) or HTTP server
+type Mirrors struct {
+	Toolchain string `yaml:"toolchain"` // Base URL of Go archives and release list, defaults to https://golang.org/dl
+	Releases  string `yaml:"releases"`  // autobuild-go release feed used by the update check
+	GoProxy   string `yaml:"goproxy"`   // GOPROXY used when installing tools, testing and building
+}
+
+// Download configures HTTP downloads of toolchains and release information
+type Download struct {
+	Retries *int   `yaml:"retries"` // Number of retries after failed attempt, defaults to 3
+	Timeout string `yaml:"timeout"` // Connect and stall timeout, e.g. 30s
+	Proxy   string `yaml:"proxy"`   // HTTP(S) proxy URL, defaults to HTTPS_PROXY/HTTP_PROXY environment
+	CAFile  string `yaml:"ca_file"` // PEM file with additional trusted certificate authorities
+}
+
+// Cache configures content-addressed build cache
+type Cache struct {
+	Dir      string `yaml:"dir"`       // Cache directory, defaults to `build-cache` in toolchain location
+	Disabled bool   `yaml:"disabled"`  // Always rebuild artifacts
+	Remote   string `yaml:"remote"`    // URL of shared cache server (GET/PUT of `<remote>/<key>/<name>`)
+	Token    string `yaml:"token"`     // Bearer token of remote cache, `$VAR` references are expanded from environment
+	Username string `yaml:"username"`  // Basic auth user of remote cache, used instead of token
+	Password string `yaml:"password"`  // Basic auth password of remote cache, `$VAR` references are expanded from environment
+	ReadOnly bool   `yaml:"read_only"` // Only download from remote cache, never upload
+	GoCache  bool   `yaml:"go_cache"`  // Share Go build cache through remote cache with GOCACHEPROG (Go 1.24+)
 }
 
 // Config is the main structure containing profiles and toolchain
 type Config struct {
 	Profiles  map[string]Profile `yaml:"profiles"`  // Map of profiles for easy selection by name
 	Toolchain Toolchain          `yaml:"toolchain"` // Toolchain configuration
+	Mirrors   Mirrors            `yaml:"mirrors"`   // Download mirrors configuration
+	Download  Download           `yaml:"download"`  // Downloader configuration
+	Tools     map[string]string  `yaml:"tools"`     // Tools installed to toolchain GOPATH, name to `package@version`
+	Cache     Cache              `yaml:"cache"`     // Build cache configuration
+}
+
+// Args represents command line arguments
+type Args struct {
+	Profile      string
+	Release      string
+	Offline      bool
+	Fix          bool
+	ChangedSince string
+	Path         string
 }
 
 type SelectedConfig struct {
 	Profile        Profile
+	ProfileName    string
 	Toolchain      Toolchain
+	Mirrors        Mirrors
+	Download       Download
+	Tools          map[string]string
+	Cache          Cache
 	CurrentVersion string
+	Offline        bool
+	Fix            bool   // Check stages (fmt, tidy) rewrite files instead of failing
+	ChangedSince   string // Git ref, only packages affected by changes since it are tested
 }
 
 func DefaultConfig(path string) SelectedConfig {
@@ -39,8 +247,24 @@ func DefaultConfig(path string) SelectedConfig {
 			},
 		},
 		Toolchain: Toolchain{
-			Golang:   "latest",
+			Golang:   GoVersions{"latest"},
 			Location: filepath.Join(filepath.Dir(path), ".toolchain"),
 		},
 	}
 }
+
+// GoNetworkEnv returns environment variables controlling module downloads of the go command,
+// honouring offline mode and configured GOPROXY mirror. Offline mode still allows file:// GOPROXY mirror
+func (c SelectedConfig) GoNetworkEnv() []string {
+	if c.Offline {
+		proxy := "off"
+		if strings.HasPrefix(c.Mirrors.GoProxy, "file://") {
+			proxy = c.Mirrors.GoProxy
+		}
+		return []string{"GOPROXY=" + proxy, "GOSUMDB=off", "GOTOOLCHAIN=local"}
+	}
+	if c.Mirrors.GoProxy != "" {
+		return []string{"GOPROXY=" + c.Mirrors.GoProxy}
+	}
+	return []string{}
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"path/filepath"
+	"runtime"
+)
+
+// GoToolchain represents a single installed Go toolchain
+type GoToolchain struct {
+	Version string // Resolved Go version, e.g. 1.22.5
+	Dir     string // Toolchain directory holding `go` (GOROOT) and `gopath` (GOPATH)
+	Root    string // GOROOT outside of Dir, set when system Go installation is reused
+}
+
+// GoRoot returns GOROOT of the toolchain
+func (t GoToolchain) GoRoot() string {
+	if t.Root != "" {
+		return t.Root
+	}
+	return filepath.Join(t.Dir, "go")
+}
+
+// GoExec returns path to the `go` binary of the toolchain
+func (t GoToolchain) GoExec() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(t.GoRoot(), "bin", "go.exe")
+	}
+	return filepath.Join(t.GoRoot(), "bin", "go")
+}
+
+// GoPath returns GOPATH of the toolchain
+func (t GoToolchain) GoPath() string {
+	return filepath.Join(t.Dir, "gopath")
+}
@@ -0,0 +1,230 @@
+package ociimage
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// Base is a base image read from `docker save` or OCI layout tarball
+type Base struct {
+	path   string
+	images []baseImage
+}
+
+// baseImage is a single platform image of the base
+type baseImage struct {
+	platform Platform
+	config   imageConfig
+	layers   []descriptor
+	blobs    map[string][]byte // Digest to contents of layers
+}
+
+// dockerManifest is an entry of manifest.json in `docker save` tarball
+type dockerManifest struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// LoadBase reads base image tarball, optionally gzip compressed. OCI layout (index.json) is preferred
+// over `docker save` manifest.json when the tarball has both
+func LoadBase(tarPath string) (*Base, error) {
+	files, err := readTar(tarPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read base image %s: %v", tarPath, err)
+	}
+
+	base := &Base{path: tarPath}
+	if index, ok := files["index.json"]; ok {
+		err = base.loadOCI(files, index)
+	} else if manifest, ok := files["manifest.json"]; ok {
+		err = base.loadDocker(files, manifest)
+	} else {
+		err = fmt.Errorf("neither index.json nor manifest.json found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid base image %s: %v", tarPath, err)
+	}
+	if len(base.images) == 0 {
+		return nil, fmt.Errorf("base image %s has no images", tarPath)
+	}
+	return base, nil
+}
+
+// image returns base image of platform. Variants only have to match when both images declare them
+func (b *Base) image(platform Platform) (baseImage, error) {
+	for _, image := range b.images {
+		if image.platform.OS != platform.OS || image.platform.Architecture != platform.Architecture {
+			continue
+		}
+		if image.platform.Variant == "" || platform.Variant == "" || image.platform.Variant == platform.Variant {
+			return image, nil
+		}
+	}
+	var available []string
+	for _, image := range b.images {
+		available = append(available, image.platform.String())
+	}
+	return baseImage{}, fmt.Errorf("base image %s has no %s image, available: %s", b.path, platform, strings.Join(available, ", "))
+}
+
+// loadOCI reads images of OCI layout, resolving nested indexes
+func (b *Base) loadOCI(files map[string][]byte, indexJSON []byte) error {
+	blob := func(digest string) ([]byte, error) {
+		contents, ok := files["blobs/"+strings.Replace(digest, ":", "/", 1)]
+		if !ok {
+			return nil, fmt.Errorf("blob %s not found", digest)
+		}
+		return contents, nil
+	}
+
+	var resolve func(contents []byte, platform *Platform) error
+	resolve = func(contents []byte, platform *Platform) error {
+		var doc struct {
+			MediaType string       `json:"mediaType"`
+			Manifests []descriptor `json:"manifests"`
+			Config    descriptor   `json:"config"`
+			Layers    []descriptor `json:"layers"`
+		}
+		if err := json.Unmarshal(contents, &doc); err != nil {
+			return err
+		}
+
+		if doc.Manifests != nil {
+			for _, manifest := range doc.Manifests {
+				if manifest.Platform != nil && manifest.Platform.Architecture == "unknown" {
+					// Attestations stored next to images
+					continue
+				}
+				child, err := blob(manifest.Digest)
+				if err != nil {
+					return err
+				}
+				if err := resolve(child, manifest.Platform); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		configJSON, err := blob(doc.Config.Digest)
+		if err != nil {
+			return err
+		}
+		image := baseImage{blobs: map[string][]byte{}}
+		if err := json.Unmarshal(configJSON, &image.config); err != nil {
+			return err
+		}
+		image.platform = Platform{OS: image.config.OS, Architecture: image.config.Architecture, Variant: image.config.Variant}
+		if platform != nil {
+			image.platform = *platform
+		}
+		for _, layer := range doc.Layers {
+			contents, err := blob(layer.Digest)
+			if err != nil {
+				return err
+			}
+			layer.MediaType = ociMediaType(layer.MediaType)
+			layer.Platform = nil
+			image.layers = append(image.layers, layer)
+			image.blobs[layer.Digest] = contents
+		}
+		b.images = append(b.images, image)
+		return nil
+	}
+	return resolve(indexJSON, nil)
+}
+
+// loadDocker reads images listed in manifest.json of `docker save` tarball
+func (b *Base) loadDocker(files map[string][]byte, manifestJSON []byte) error {
+	var manifests []dockerManifest
+	if err := json.Unmarshal(manifestJSON, &manifests); err != nil {
+		return err
+	}
+	for _, manifest := range manifests {
+		configJSON, ok := files[manifest.Config]
+		if !ok {
+			return fmt.Errorf("config %s not found", manifest.Config)
+		}
+		image := baseImage{blobs: map[string][]byte{}}
+		if err := json.Unmarshal(configJSON, &image.config); err != nil {
+			return err
+		}
+		image.platform = Platform{OS: image.config.OS, Architecture: image.config.Architecture, Variant: image.config.Variant}
+		for _, layerPath := range manifest.Layers {
+			contents, ok := files[layerPath]
+			if !ok {
+				return fmt.Errorf("layer %s not found", layerPath)
+			}
+			mediaType := mediaTypeLayer
+			if len(contents) > 2 && contents[0] == 0x1f && contents[1] == 0x8b {
+				mediaType = mediaTypeLayerGzip
+			}
+			digest := sha256.Sum256(contents)
+			layer := descriptor{MediaType: mediaType, Digest: "sha256:" + hex.EncodeToString(digest[:]), Size: int64(len(contents))}
+			image.layers = append(image.layers, layer)
+			image.blobs[layer.Digest] = contents
+		}
+		b.images = append(b.images, image)
+	}
+	return nil
+}
+
+// readTar reads all regular files of tarball into memory
+func readTar(tarPath string) (map[string][]byte, error) {
+	file, err := os.Open(tarPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var in io.Reader = reader
+	if magic, err := reader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		in = gz
+	}
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(in)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		contents, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[path.Clean(strings.TrimPrefix(header.Name, "./"))] = contents
+	}
+}
+
+// ociMediaType converts Docker layer media types to their OCI equivalents with the same contents
+func ociMediaType(mediaType string) string {
+	switch mediaType {
+	case "application/vnd.docker.image.rootfs.diff.tar.gzip":
+		return mediaTypeLayerGzip
+	case "application/vnd.docker.image.rootfs.diff.tar":
+		return mediaTypeLayer
+	}
+	return mediaType
+}
@@ -0,0 +1,420 @@
+// Package ociimage assembles OCI container images from local files without a container daemon
+package ociimage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	mediaTypeIndex     = "application/vnd.oci.image.index.v1+json"
+	mediaTypeManifest  = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeConfig    = "application/vnd.oci.image.config.v1+json"
+	mediaTypeLayer     = "application/vnd.oci.image.layer.v1.tar"
+	mediaTypeLayerGzip = "application/vnd.oci.image.layer.v1.tar+gzip"
+)
+
+// defaultPath is PATH of images built from scratch
+const defaultPath = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// Platform identifies operating system and architecture of an image
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+func (p Platform) String() string {
+	if p.Variant == "" {
+		return p.OS + "/" + p.Architecture
+	}
+	return p.OS + "/" + p.Architecture + "/" + p.Variant
+}
+
+// File is a file added to the image layer
+type File struct {
+	Path   string // Absolute path in the image
+	Source string // Local file with contents
+	Mode   fs.FileMode
+}
+
+// Config is runtime configuration of the image, empty values keep settings of the base image
+type Config struct {
+	Entrypoint []string
+	Cmd        []string
+	Env        []string // KEY=value, replacing variables of the base image
+	WorkingDir string
+	User       string
+	Ports      []string // e.g. 8080/tcp or 53/udp
+	Labels     map[string]string
+}
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Platform    *Platform         `json:"platform,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Config        *descriptor  `json:"config,omitempty"`
+	Layers        []descriptor `json:"layers,omitempty"`
+	Manifests     []descriptor `json:"manifests,omitempty"`
+}
+
+// imageConfig is OCI image configuration, unknown fields of base images are dropped
+type imageConfig struct {
+	Created      string         `json:"created,omitempty"`
+	Architecture string         `json:"architecture"`
+	OS           string         `json:"os"`
+	Variant      string         `json:"variant,omitempty"`
+	Config       runtimeConfig  `json:"config"`
+	RootFS       rootFS         `json:"rootfs"`
+	History      []historyEntry `json:"history,omitempty"`
+}
+
+type runtimeConfig struct {
+	User         string              `json:"User,omitempty"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+	Env          []string            `json:"Env,omitempty"`
+	Entrypoint   []string            `json:"Entrypoint,omitempty"`
+	Cmd          []string            `json:"Cmd,omitempty"`
+	WorkingDir   string              `json:"WorkingDir,omitempty"`
+	Labels       map[string]string   `json:"Labels,omitempty"`
+	StopSignal   string              `json:"StopSignal,omitempty"`
+}
+
+type rootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids"`
+}
+
+type historyEntry struct {
+	Created    string `json:"created,omitempty"`
+	CreatedBy  string `json:"created_by,omitempty"`
+	Comment    string `json:"comment,omitempty"`
+	EmptyLayer bool   `json:"empty_layer,omitempty"`
+}
+
+// dockerImage is an image listed in manifest.json of `docker load` tarball
+type dockerImage struct {
+	config string
+	layers []string
+	suffix string // Tag suffix distinguishing platforms, e.g. `-arm64`
+}
+
+// Layout is an OCI image layout with a multi-platform image assembled in memory
+type Layout struct {
+	blobs     map[string][]byte
+	manifests []descriptor
+	docker    []dockerImage
+	created   time.Time
+}
+
+// NewLayout creates empty layout, created is the creation time of images and of all files
+func NewLayout(created time.Time) *Layout {
+	return &Layout{blobs: map[string][]byte{}, created: created.UTC()}
+}
+
+var portPattern = regexp.MustCompile(`^[0-9]+(/(tcp|udp|sctp))?$`)
+
+// AddImage adds image of platform made of base (nil for scratch) and a layer with files.
+// tagSuffix distinguishes the platform in manifest.json of `docker load` tarball
+func (l *Layout) AddImage(base *Base, platform Platform, files []File, conf Config, tagSuffix string) error {
+	image := baseImage{platform: platform, config: imageConfig{Config: runtimeConfig{Env: []string{defaultPath}}}}
+	if base != nil {
+		var err error
+		if image, err = base.image(platform); err != nil {
+			return err
+		}
+		for digest, contents := range image.blobs {
+			l.blobs[digest] = contents
+		}
+	}
+
+	layer, diffID, err := l.layer(files)
+	if err != nil {
+		return err
+	}
+
+	created := l.created.Format(time.RFC3339)
+	config := image.config
+	config.Created = created
+	config.OS, config.Architecture, config.Variant = platform.OS, platform.Architecture, platform.Variant
+	config.RootFS = rootFS{Type: "layers", DiffIDs: append(append([]string{}, config.RootFS.DiffIDs...), diffID)}
+	config.History = append(append([]historyEntry{}, config.History...), historyEntry{Created: created, CreatedBy: "autobuild-go oci", Comment: "application layer"})
+	if err := applyConfig(&config.Config, conf); err != nil {
+		return err
+	}
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	configDesc := l.addBlob(mediaTypeConfig, configJSON)
+	layers := append(append([]descriptor{}, image.layers...), layer)
+	manifestJSON, err := json.Marshal(manifest{SchemaVersion: 2, MediaType: mediaTypeManifest, Config: &configDesc, Layers: layers})
+	if err != nil {
+		return err
+	}
+	manifestDesc := l.addBlob(mediaTypeManifest, manifestJSON)
+	manifestDesc.Platform = &platform
+	l.manifests = append(l.manifests, manifestDesc)
+
+	docker := dockerImage{config: blobPath(configDesc.Digest), suffix: tagSuffix}
+	for _, layer := range layers {
+		docker.layers = append(docker.layers, blobPath(layer.Digest))
+	}
+	l.docker = append(l.docker, docker)
+	return nil
+}
+
+// applyConfig applies configuration over runtime configuration of the base image
+func applyConfig(runtime *runtimeConfig, conf Config) error {
+	if len(conf.Entrypoint) > 0 {
+		// Command of the base image would become arguments of the new entrypoint
+		runtime.Entrypoint, runtime.Cmd = conf.Entrypoint, nil
+	}
+	if len(conf.Cmd) > 0 {
+		runtime.Cmd = conf.Cmd
+	}
+	if conf.WorkingDir != "" {
+		runtime.WorkingDir = conf.WorkingDir
+	}
+	if conf.User != "" {
+		runtime.User = conf.User
+	}
+	for _, variable := range conf.Env {
+		name, _, found := strings.Cut(variable, "=")
+		if !found {
+			return fmt.Errorf("invalid environment variable `%s`, use KEY=value", variable)
+		}
+		env := []string{}
+		for _, existing := range runtime.Env {
+			if !strings.HasPrefix(existing, name+"=") {
+				env = append(env, existing)
+			}
+		}
+		runtime.Env = append(env, variable)
+	}
+	if len(conf.Ports) > 0 {
+		// Maps of the base image are shared between platforms using it
+		ports := map[string]struct{}{}
+		for port := range runtime.ExposedPorts {
+			ports[port] = struct{}{}
+		}
+		for _, port := range conf.Ports {
+			if !portPattern.MatchString(port) {
+				return fmt.Errorf("invalid port `%s`, use e.g. 8080 or 53/udp", port)
+			}
+			if !strings.Contains(port, "/") {
+				port += "/tcp"
+			}
+			ports[port] = struct{}{}
+		}
+		runtime.ExposedPorts = ports
+	}
+	if len(conf.Labels) > 0 {
+		labels := map[string]string{}
+		for name, value := range runtime.Labels {
+			labels[name] = value
+		}
+		for name, value := range conf.Labels {
+			labels[name] = value
+		}
+		runtime.Labels = labels
+	}
+	return nil
+}
+
+// layer creates gzip compressed layer with files and their parent directories, owned by root.
+// Returns its descriptor and digest of uncompressed contents (diff ID)
+func (l *Layout) layer(files []File) (descriptor, string, error) {
+	entries := map[string]*File{}
+	for i := range files {
+		file := &files[i]
+		if !path.IsAbs(file.Path) {
+			return descriptor{}, "", fmt.Errorf("image path `%s` has to be absolute", file.Path)
+		}
+		entries[strings.TrimPrefix(file.Path, "/")] = file
+		for dir := path.Dir(file.Path); dir != "/"; dir = path.Dir(dir) {
+			if _, ok := entries[strings.TrimPrefix(dir, "/")+"/"]; !ok {
+				entries[strings.TrimPrefix(dir, "/")+"/"] = nil
+			}
+		}
+	}
+	var names []string
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var uncompressed bytes.Buffer
+	tw := tar.NewWriter(&uncompressed)
+	for _, name := range names {
+		header := &tar.Header{Name: name, ModTime: l.created, Uname: "root", Gname: "root"}
+		file := entries[name]
+		if file == nil {
+			header.Typeflag, header.Mode = tar.TypeDir, 0o755
+			if err := tw.WriteHeader(header); err != nil {
+				return descriptor{}, "", err
+			}
+			continue
+		}
+		contents, err := os.ReadFile(file.Source)
+		if err != nil {
+			return descriptor{}, "", err
+		}
+		header.Typeflag, header.Mode, header.Size = tar.TypeReg, int64(file.Mode.Perm()), int64(len(contents))
+		if err := tw.WriteHeader(header); err != nil {
+			return descriptor{}, "", err
+		}
+		if _, err := tw.Write(contents); err != nil {
+			return descriptor{}, "", err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return descriptor{}, "", err
+	}
+	diffID := sha256.Sum256(uncompressed.Bytes())
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(uncompressed.Bytes()); err != nil {
+		return descriptor{}, "", err
+	}
+	if err := gz.Close(); err != nil {
+		return descriptor{}, "", err
+	}
+	return l.addBlob(mediaTypeLayerGzip, compressed.Bytes()), "sha256:" + hex.EncodeToString(diffID[:]), nil
+}
+
+func (l *Layout) addBlob(mediaType string, contents []byte) descriptor {
+	digest := sha256.Sum256(contents)
+	desc := descriptor{MediaType: mediaType, Digest: "sha256:" + hex.EncodeToString(digest[:]), Size: int64(len(contents))}
+	l.blobs[desc.Digest] = contents
+	return desc
+}
+
+func blobPath(digest string) string {
+	return "blobs/" + strings.Replace(digest, ":", "/", 1)
+}
+
+// Write stores layout in dir and, when tarPath is not empty, as tarball loadable with `docker load`.
+// Every tag of name references the multi-platform index. Returns digest of the index
+func (l *Layout) Write(dir string, tarPath string, name string, tags []string) (string, error) {
+	indexJSON, err := json.Marshal(manifest{SchemaVersion: 2, MediaType: mediaTypeIndex, Manifests: l.manifests})
+	if err != nil {
+		return "", err
+	}
+	indexDesc := l.addBlob(mediaTypeIndex, indexJSON)
+
+	var refs []descriptor
+	var dockerManifests []dockerManifest
+	for _, tag := range tags {
+		ref := indexDesc
+		ref.Annotations = map[string]string{
+			"org.opencontainers.image.ref.name": tag,
+			"io.containerd.image.name":          name + ":" + tag,
+		}
+		refs = append(refs, ref)
+	}
+	for _, image := range l.docker {
+		var repoTags []string
+		for _, tag := range tags {
+			if len(l.docker) > 1 {
+				// manifest.json has no platforms, only one image can have the tag
+				tag += image.suffix
+			}
+			repoTags = append(repoTags, name+":"+tag)
+		}
+		dockerManifests = append(dockerManifests, dockerManifest{Config: image.config, RepoTags: repoTags, Layers: image.layers})
+	}
+	layoutJSON, err := json.Marshal(manifest{SchemaVersion: 2, MediaType: mediaTypeIndex, Manifests: refs})
+	if err != nil {
+		return "", err
+	}
+	dockerJSON, err := json.Marshal(dockerManifests)
+	if err != nil {
+		return "", err
+	}
+
+	files := map[string][]byte{
+		"oci-layout": []byte(`{"imageLayoutVersion":"1.0.0"}`),
+		"index.json": layoutJSON,
+	}
+	for digest, contents := range l.blobs {
+		files[blobPath(digest)] = contents
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	for file, contents := range files {
+		filePath := filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(filePath, contents, 0o644); err != nil {
+			return "", err
+		}
+	}
+
+	if tarPath != "" {
+		files["manifest.json"] = dockerJSON
+		if err := l.writeTar(tarPath, files); err != nil {
+			return "", err
+		}
+	}
+	return indexDesc.Digest, nil
+}
+
+// writeTar writes files sorted by name with fixed modification time
+func (l *Layout) writeTar(tarPath string, files map[string][]byte) error {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out, err := os.Create(tarPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	tw := tar.NewWriter(out)
+	for _, dir := range []string{"blobs/", "blobs/sha256/"} {
+		if err := tw.WriteHeader(&tar.Header{Name: dir, Typeflag: tar.TypeDir, Mode: 0o755, ModTime: l.created}); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		contents := files[name]
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(contents)), ModTime: l.created}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(contents); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
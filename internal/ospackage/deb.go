@@ -0,0 +1,225 @@
+package ospackage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DebFileName returns conventional file name of Debian package, e.g. app_1.2.3-1_amd64.deb
+func DebFileName(p Package) string {
+	return fmt.Sprintf("%s_%s-%s_%s.deb", p.Name, packageVersion(p.Version), p.Release, p.Arch)
+}
+
+// WriteDeb writes Debian binary package: ar archive of debian-binary, control.tar.gz and data.tar.gz
+func WriteDeb(w io.Writer, p Package) error {
+	if err := p.check(); err != nil {
+		return err
+	}
+
+	var data bytes.Buffer
+	md5sums, installedSize, err := p.debData(&data)
+	if err != nil {
+		return err
+	}
+	var control bytes.Buffer
+	if err := p.debControl(&control, md5sums, installedSize); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, "!<arch>\n"); err != nil {
+		return err
+	}
+	for _, member := range []struct {
+		name     string
+		contents []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar.gz", control.Bytes()},
+		{"data.tar.gz", data.Bytes()},
+	} {
+		header := fmt.Sprintf("%-16s%-12d%-6d%-6d%-8s%-10d`\n", member.name, p.Modified.Unix(), 0, 0, "100644", len(member.contents))
+		if _, err := io.WriteString(w, header); err != nil {
+			return err
+		}
+		if _, err := w.Write(member.contents); err != nil {
+			return err
+		}
+		// Members are aligned to even offsets
+		if len(member.contents)%2 == 1 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// debData writes data.tar.gz with installed files and their parent directories.
+// Returns md5sums file contents and installed size in KiB
+func (p *Package) debData(w io.Writer) (string, int64, error) {
+	tw := newTarGz(w, p.Modified)
+
+	dirs := map[string]bool{}
+	for _, file := range p.Files {
+		for dir := path.Dir(file.Path); dir != "/"; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	var sortedDirs []string
+	for dir := range dirs {
+		sortedDirs = append(sortedDirs, dir)
+	}
+	sort.Strings(sortedDirs)
+	if err := tw.dir("./"); err != nil {
+		return "", 0, err
+	}
+	for _, dir := range sortedDirs {
+		if err := tw.dir("." + dir + "/"); err != nil {
+			return "", 0, err
+		}
+	}
+
+	var md5sums strings.Builder
+	var size int64
+	for _, file := range p.Files {
+		contents, err := os.ReadFile(file.Source)
+		if err != nil {
+			return "", 0, err
+		}
+		if err := tw.file("."+file.Path, file.Mode, contents); err != nil {
+			return "", 0, err
+		}
+		sum := md5.Sum(contents)
+		fmt.Fprintf(&md5sums, "%s  %s\n", hex.EncodeToString(sum[:]), strings.TrimPrefix(file.Path, "/"))
+		size += int64(len(contents))
+	}
+	return md5sums.String(), (size + 1023) / 1024, tw.close()
+}
+
+// debControl writes control.tar.gz with control file, checksums, configuration files and maintainer scripts
+func (p *Package) debControl(w io.Writer, md5sums string, installedSize int64) error {
+	var depends []string
+	for _, text := range p.Depends {
+		dep, err := parseDependency(text)
+		if err != nil {
+			return err
+		}
+		if dep.operator == "" {
+			depends = append(depends, dep.name)
+			continue
+		}
+		operator := map[string]string{"<": "<<", ">": ">>"}[dep.operator]
+		if operator == "" {
+			operator = dep.operator
+		}
+		depends = append(depends, fmt.Sprintf("%s (%s %s)", dep.name, operator, dep.version))
+	}
+
+	var control strings.Builder
+	field := func(name string, value string) {
+		if value != "" {
+			fmt.Fprintf(&control, "%s: %s\n", name, value)
+		}
+	}
+	field("Package", p.Name)
+	field("Version", packageVersion(p.Version)+"-"+p.Release)
+	field("Architecture", p.Arch)
+	field("Maintainer", p.Maintainer)
+	field("Installed-Size", fmt.Sprint(installedSize))
+	field("Depends", strings.Join(depends, ", "))
+	field("Priority", "optional")
+	field("Homepage", p.Homepage)
+	field("Description", p.summary())
+	// Extended description lines start with space, empty lines are ` .`
+	if _, rest, found := strings.Cut(strings.TrimSpace(p.Description), "\n"); found {
+		for _, line := range strings.Split(strings.TrimSpace(rest), "\n") {
+			if line = strings.TrimRight(line, " \t"); line == "" {
+				line = "."
+			}
+			control.WriteString(" " + line + "\n")
+		}
+	}
+
+	tw := newTarGz(w, p.Modified)
+	if err := tw.dir("./"); err != nil {
+		return err
+	}
+	if err := tw.file("./control", 0o644, []byte(control.String())); err != nil {
+		return err
+	}
+	if err := tw.file("./md5sums", 0o644, []byte(md5sums)); err != nil {
+		return err
+	}
+	var conffiles strings.Builder
+	for _, file := range p.Files {
+		if file.Config {
+			conffiles.WriteString(file.Path + "\n")
+		}
+	}
+	if conffiles.Len() > 0 {
+		if err := tw.file("./conffiles", 0o644, []byte(conffiles.String())); err != nil {
+			return err
+		}
+	}
+	for _, script := range []struct{ name, contents string }{
+		{"preinst", p.Scripts.PreInstall},
+		{"postinst", p.Scripts.PostInstall},
+		{"prerm", p.Scripts.PreRemove},
+		{"postrm", p.Scripts.PostRemove},
+	} {
+		if script.contents != "" {
+			if err := tw.file("./"+script.name, 0o755, []byte(script.contents)); err != nil {
+				return err
+			}
+		}
+	}
+	return tw.close()
+}
+
+// tarGz writes gzip compressed tar archive with entries owned by root and dated with fixed time
+type tarGz struct {
+	gz       *gzip.Writer
+	tw       *tar.Writer
+	modified time.Time
+}
+
+func newTarGz(w io.Writer, modified time.Time) *tarGz {
+	gz := gzip.NewWriter(w)
+	return &tarGz{gz: gz, tw: tar.NewWriter(gz), modified: modified}
+}
+
+func (t *tarGz) dir(name string) error {
+	return t.write(&tar.Header{Name: name, Mode: 0o755, Typeflag: tar.TypeDir}, nil)
+}
+
+func (t *tarGz) file(name string, mode fs.FileMode, contents []byte) error {
+	return t.write(&tar.Header{Name: name, Mode: int64(mode.Perm()), Typeflag: tar.TypeReg, Size: int64(len(contents))}, contents)
+}
+
+func (t *tarGz) write(header *tar.Header, contents []byte) error {
+	header.Uname, header.Gname = "root", "root"
+	header.ModTime = t.modified
+	if err := t.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := t.tw.Write(contents)
+	return err
+}
+
+func (t *tarGz) close() error {
+	if err := t.tw.Close(); err != nil {
+		return err
+	}
+	return t.gz.Close()
+}
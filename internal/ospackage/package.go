@@ -0,0 +1,156 @@
+// Package ospackage writes Debian and RPM packages without external tools
+package ospackage
+
+import (
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// File is a file installed by package
+type File struct {
+	Path   string // Absolute install path
+	Source string // Local file with contents
+	Mode   fs.FileMode
+	Config bool // Configuration file, local changes are kept on upgrade
+}
+
+// Scripts are maintainer scripts run by package manager, empty scripts are omitted
+type Scripts struct {
+	PreInstall  string
+	PostInstall string
+	PreRemove   string
+	PostRemove  string
+}
+
+// Package describes package metadata and contents
+type Package struct {
+	Name        string
+	Version     string // Semantic version, converted to the format of package manager
+	Release     string // Package release (RPM Release, Debian revision)
+	Arch        string // Architecture name of the package format, see DebArch and RPMArch
+	Maintainer  string
+	Vendor      string
+	Homepage    string
+	License     string
+	Description string // First line is the summary
+	Depends     []string
+	Scripts     Scripts
+	Files       []File
+	Modified    time.Time // Time stored for all files and the build time
+}
+
+// validName matches package names accepted by both dpkg and rpm
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9+.-]*$`)
+
+// check validates package and sorts files by install path
+func (p *Package) check() error {
+	if !validName.MatchString(p.Name) {
+		return fmt.Errorf("invalid package name `%s`, only lowercase letters, digits, `+`, `-` and `.` are allowed", p.Name)
+	}
+	if p.Version == "" || p.Arch == "" {
+		return fmt.Errorf("package %s needs version and architecture", p.Name)
+	}
+	seen := map[string]bool{}
+	for _, file := range p.Files {
+		if !strings.HasPrefix(file.Path, "/") || strings.HasSuffix(file.Path, "/") {
+			return fmt.Errorf("install path `%s` has to be an absolute file path", file.Path)
+		}
+		if seen[file.Path] {
+			return fmt.Errorf("install path `%s` used twice", file.Path)
+		}
+		seen[file.Path] = true
+	}
+	sort.Slice(p.Files, func(i, j int) bool { return p.Files[i].Path < p.Files[j].Path })
+	return nil
+}
+
+// summary returns first line of description
+func (p *Package) summary() string {
+	summary, _, _ := strings.Cut(strings.TrimSpace(p.Description), "\n")
+	if summary == "" {
+		return p.Name
+	}
+	return summary
+}
+
+// packageVersion converts semantic version to Debian and RPM version: every `-` becomes `~`, which sorts before
+// the release, `+` of build metadata is valid in both and kept, e.g. 1.2.4-snapshot.5+abc1234-dirty gives
+// 1.2.4~snapshot.5+abc1234~dirty
+func packageVersion(version string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(version, "v"), "-", "~")
+}
+
+// dependency is a parsed package relation, e.g. `libc6 (>= 2.17)` or `glibc >= 2.17`
+type dependency struct {
+	name     string
+	operator string // <, <=, =, >=, > or empty
+	version  string
+}
+
+var dependencyPattern = regexp.MustCompile(`^([^\s(<>=]+)\s*\(?\s*(<<|>>|<=|>=|=|<|>)?\s*([^\s)]*)\s*\)?$`)
+
+func parseDependency(text string) (dependency, error) {
+	match := dependencyPattern.FindStringSubmatch(strings.TrimSpace(text))
+	if match == nil || (match[2] == "") != (match[3] == "") {
+		return dependency{}, fmt.Errorf("invalid dependency `%s`, use `name` or `name >= version`", text)
+	}
+	operator := match[2]
+	switch operator {
+	case "<<":
+		operator = "<"
+	case ">>":
+		operator = ">"
+	}
+	return dependency{match[1], operator, match[3]}, nil
+}
+
+// DebArch returns Debian architecture of Go target, false when Debian has no such architecture
+func DebArch(goarch string, variant string) (string, bool) {
+	switch goarch {
+	case "arm":
+		if strings.HasPrefix(variant, "5") || strings.HasPrefix(variant, "6") {
+			return "armel", true
+		}
+		return "armhf", true
+	case "386":
+		return "i386", true
+	case "ppc64le":
+		return "ppc64el", true
+	case "mipsle":
+		return "mipsel", true
+	case "mips64le":
+		return "mips64el", true
+	case "amd64", "arm64", "ppc64", "riscv64", "s390x", "loong64", "mips", "mips64":
+		return goarch, true
+	}
+	return "", false
+}
+
+// RPMArch returns RPM architecture of Go target, e.g. x86_64 for amd64, false when RPM has no such architecture
+func RPMArch(goarch string, variant string) (string, bool) {
+	switch goarch {
+	case "amd64":
+		return "x86_64", true
+	case "arm64":
+		return "aarch64", true
+	case "386":
+		return "i686", true
+	case "arm":
+		if strings.HasPrefix(variant, "5") {
+			return "armv5tel", true
+		}
+		if strings.HasPrefix(variant, "6") {
+			return "armv6hl", true
+		}
+		return "armv7hl", true
+	case "loong64":
+		return "loongarch64", true
+	case "ppc64", "ppc64le", "riscv64", "s390x":
+		return goarch, true
+	}
+	return "", false
+}
@@ -0,0 +1,402 @@
+package ospackage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// RPM header data types
+const (
+	rpmInt16       = 3
+	rpmInt32       = 4
+	rpmString      = 6
+	rpmBin         = 7
+	rpmStringArray = 8
+	rpmI18NString  = 9
+)
+
+// RPM header tags, see rpmtag.h
+const (
+	tagHeaderSignatures  = 62
+	tagHeaderImmutable   = 63
+	tagHeaderI18NTable   = 100
+	tagSigSHA1           = 269
+	tagSigSHA256         = 273
+	tagSigSize           = 1000
+	tagSigMD5            = 1004
+	tagSigPayloadSize    = 1007
+	tagName              = 1000
+	tagVersion           = 1001
+	tagRelease           = 1002
+	tagSummary           = 1004
+	tagDescription       = 1005
+	tagBuildTime         = 1006
+	tagBuildHost         = 1007
+	tagSize              = 1009
+	tagVendor            = 1011
+	tagLicense           = 1014
+	tagPackager          = 1015
+	tagGroup             = 1016
+	tagURL               = 1020
+	tagOS                = 1021
+	tagArch              = 1022
+	tagPreIn             = 1023
+	tagPostIn            = 1024
+	tagPreUn             = 1025
+	tagPostUn            = 1026
+	tagFileSizes         = 1028
+	tagFileModes         = 1030
+	tagFileRdevs         = 1033
+	tagFileMtimes        = 1034
+	tagFileDigests       = 1035
+	tagFileLinkTos       = 1036
+	tagFileFlags         = 1037
+	tagFileUserName      = 1039
+	tagFileGroupName     = 1040
+	tagSourceRPM         = 1044
+	tagFileVerifyFlags   = 1045
+	tagProvideName       = 1047
+	tagRequireFlags      = 1048
+	tagRequireName       = 1049
+	tagRequireVersion    = 1050
+	tagPreInProg         = 1085
+	tagPostInProg        = 1086
+	tagPreUnProg         = 1087
+	tagPostUnProg        = 1088
+	tagFileDevices       = 1095
+	tagFileInodes        = 1096
+	tagFileLangs         = 1097
+	tagProvideFlags      = 1112
+	tagProvideVersion    = 1113
+	tagDirIndexes        = 1116
+	tagBaseNames         = 1117
+	tagDirNames          = 1118
+	tagPayloadFormat     = 1124
+	tagPayloadCompressor = 1125
+	tagPayloadFlags      = 1126
+	tagFileDigestAlgo    = 5011
+	tagPayloadDigest     = 5092
+	tagPayloadDigestAlgo = 5093
+)
+
+// Dependency flags (rpmds.h) and file flags (rpmfiles.h)
+const (
+	senseLess     = 1 << 1
+	senseGreater  = 1 << 2
+	senseEqual    = 1 << 3
+	senseRPMLib   = 1 << 24
+	fileConfig    = 1 << 0
+	fileNoReplace = 1 << 4
+	digestSHA256  = 8
+)
+
+// RPMFileName returns conventional file name of RPM package, e.g. app-1.2.3-1.x86_64.rpm
+func RPMFileName(p Package) string {
+	return fmt.Sprintf("%s-%s-%s.%s.rpm", p.Name, packageVersion(p.Version), p.Release, p.Arch)
+}
+
+// WriteRPM writes RPM v3 binary package: lead, signature header, header and gzip compressed cpio payload
+func WriteRPM(w io.Writer, p Package) error {
+	if err := p.check(); err != nil {
+		return err
+	}
+	version := packageVersion(p.Version)
+
+	// Payload: cpio archive of files, paths prefixed with `.`
+	var cpio bytes.Buffer
+	var sizes, mtimes, flags, inodes, devices, verifyFlags, dirIndexes []int32
+	var modes, rdevs []int16
+	var digests, linkTos, users, groups, langs, baseNames, dirNames []string
+	dirIndex := map[string]int32{}
+	var installedSize int32
+	for i, file := range p.Files {
+		contents, err := os.ReadFile(file.Source)
+		if err != nil {
+			return err
+		}
+		mode := uint32(0o100000 | file.Mode.Perm())
+		writeCpioEntry(&cpio, "."+file.Path, uint32(i+1), mode, p.Modified.Unix(), contents)
+
+		dir, base := path.Dir(file.Path)+"/", path.Base(file.Path)
+		if _, ok := dirIndex[dir]; !ok {
+			dirIndex[dir] = int32(len(dirNames))
+			dirNames = append(dirNames, dir)
+		}
+		digest := sha256.Sum256(contents)
+		fileFlags := int32(0)
+		if file.Config {
+			fileFlags = fileConfig | fileNoReplace
+		}
+
+		sizes = append(sizes, int32(len(contents)))
+		modes = append(modes, int16(mode))
+		rdevs = append(rdevs, 0)
+		mtimes = append(mtimes, int32(p.Modified.Unix()))
+		digests = append(digests, hex.EncodeToString(digest[:]))
+		linkTos = append(linkTos, "")
+		flags = append(flags, fileFlags)
+		users = append(users, "root")
+		groups = append(groups, "root")
+		verifyFlags = append(verifyFlags, -1)
+		devices = append(devices, 1)
+		inodes = append(inodes, int32(i+1))
+		langs = append(langs, "")
+		dirIndexes = append(dirIndexes, dirIndex[dir])
+		baseNames = append(baseNames, base)
+		installedSize += int32(len(contents))
+	}
+	writeCpioEntry(&cpio, "TRAILER!!!", 0, 0, 0, nil)
+	var payload bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&payload, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := gz.Write(cpio.Bytes()); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	payloadDigest := sha256.Sum256(payload.Bytes())
+
+	// Dependencies, including rpmlib features used by this package format
+	requireNames := []string{"rpmlib(CompressedFileNames)", "rpmlib(FileDigests)", "rpmlib(PayloadFilesHavePrefix)"}
+	requireVersions := []string{"3.0.4-1", "4.6.0-1", "4.0-1"}
+	requireFlags := []int32{senseLess | senseEqual | senseRPMLib, senseLess | senseEqual | senseRPMLib, senseLess | senseEqual | senseRPMLib}
+	if strings.Contains(version, "~") {
+		requireNames = append(requireNames, "rpmlib(TildeInVersions)")
+		requireVersions = append(requireVersions, "4.10.0-1")
+		requireFlags = append(requireFlags, senseLess|senseEqual|senseRPMLib)
+	}
+	scripts := p.Scripts.PreInstall + p.Scripts.PostInstall + p.Scripts.PreRemove + p.Scripts.PostRemove
+	if scripts != "" {
+		requireNames, requireVersions, requireFlags = append(requireNames, "/bin/sh"), append(requireVersions, ""), append(requireFlags, 0)
+	}
+	for _, text := range p.Depends {
+		dep, err := parseDependency(text)
+		if err != nil {
+			return err
+		}
+		requireNames = append(requireNames, dep.name)
+		requireVersions = append(requireVersions, dep.version)
+		requireFlags = append(requireFlags, map[string]int32{
+			"<":  senseLess,
+			"<=": senseLess | senseEqual,
+			"=":  senseEqual,
+			">=": senseGreater | senseEqual,
+			">":  senseGreater,
+		}[dep.operator])
+	}
+
+	header := &rpmHeader{}
+	header.addStrings(tagHeaderI18NTable, rpmStringArray, "C")
+	header.addStrings(tagName, rpmString, p.Name)
+	header.addStrings(tagVersion, rpmString, version)
+	header.addStrings(tagRelease, rpmString, p.Release)
+	header.addStrings(tagSummary, rpmI18NString, p.summary())
+	header.addStrings(tagDescription, rpmI18NString, p.Description)
+	header.addInt32(tagBuildTime, int32(p.Modified.Unix()))
+	header.addStrings(tagBuildHost, rpmString, "localhost")
+	header.addInt32(tagSize, installedSize)
+	header.addStrings(tagLicense, rpmString, p.License)
+	header.addStrings(tagGroup, rpmI18NString, "Unspecified")
+	header.addStrings(tagOS, rpmString, "linux")
+	header.addStrings(tagArch, rpmString, p.Arch)
+	header.addStrings(tagSourceRPM, rpmString, fmt.Sprintf("%s-%s-%s.src.rpm", p.Name, version, p.Release))
+	header.addStrings(tagProvideName, rpmStringArray, p.Name)
+	header.addInt32(tagProvideFlags, senseEqual)
+	header.addStrings(tagProvideVersion, rpmStringArray, version+"-"+p.Release)
+	header.addStrings(tagRequireName, rpmStringArray, requireNames...)
+	header.addInt32(tagRequireFlags, requireFlags...)
+	header.addStrings(tagRequireVersion, rpmStringArray, requireVersions...)
+	header.addStrings(tagPayloadFormat, rpmString, "cpio")
+	header.addStrings(tagPayloadCompressor, rpmString, "gzip")
+	header.addStrings(tagPayloadFlags, rpmString, "9")
+	header.addStrings(tagPayloadDigest, rpmStringArray, hex.EncodeToString(payloadDigest[:]))
+	header.addInt32(tagPayloadDigestAlgo, digestSHA256)
+	for _, optional := range []struct {
+		tag   int32
+		value string
+	}{
+		{tagVendor, p.Vendor},
+		{tagPackager, p.Maintainer},
+		{tagURL, p.Homepage},
+	} {
+		if optional.value != "" {
+			header.addStrings(optional.tag, rpmString, optional.value)
+		}
+	}
+	for _, script := range []struct {
+		tag, progTag int32
+		contents     string
+	}{
+		{tagPreIn, tagPreInProg, p.Scripts.PreInstall},
+		{tagPostIn, tagPostInProg, p.Scripts.PostInstall},
+		{tagPreUn, tagPreUnProg, p.Scripts.PreRemove},
+		{tagPostUn, tagPostUnProg, p.Scripts.PostRemove},
+	} {
+		if script.contents != "" {
+			header.addStrings(script.tag, rpmString, script.contents)
+			header.addStrings(script.progTag, rpmString, "/bin/sh")
+		}
+	}
+	if len(p.Files) > 0 {
+		header.addInt32(tagFileSizes, sizes...)
+		header.addInt16(tagFileModes, modes...)
+		header.addInt16(tagFileRdevs, rdevs...)
+		header.addInt32(tagFileMtimes, mtimes...)
+		header.addStrings(tagFileDigests, rpmStringArray, digests...)
+		header.addStrings(tagFileLinkTos, rpmStringArray, linkTos...)
+		header.addInt32(tagFileFlags, flags...)
+		header.addStrings(tagFileUserName, rpmStringArray, users...)
+		header.addStrings(tagFileGroupName, rpmStringArray, groups...)
+		header.addInt32(tagFileVerifyFlags, verifyFlags...)
+		header.addInt32(tagFileDevices, devices...)
+		header.addInt32(tagFileInodes, inodes...)
+		header.addStrings(tagFileLangs, rpmStringArray, langs...)
+		header.addInt32(tagDirIndexes, dirIndexes...)
+		header.addStrings(tagBaseNames, rpmStringArray, baseNames...)
+		header.addStrings(tagDirNames, rpmStringArray, dirNames...)
+		header.addInt32(tagFileDigestAlgo, digestSHA256)
+	}
+	headerBytes := header.bytes(tagHeaderImmutable)
+
+	// Signature header covers the header and the payload
+	headerSHA1 := sha1.Sum(headerBytes)
+	headerSHA256 := sha256.Sum256(headerBytes)
+	md5Hash := md5.New()
+	md5Hash.Write(headerBytes)
+	md5Hash.Write(payload.Bytes())
+	signature := &rpmHeader{}
+	signature.addStrings(tagSigSHA1, rpmString, hex.EncodeToString(headerSHA1[:]))
+	signature.addStrings(tagSigSHA256, rpmString, hex.EncodeToString(headerSHA256[:]))
+	signature.addInt32(tagSigSize, int32(len(headerBytes)+payload.Len()))
+	signature.addBin(tagSigMD5, md5Hash.Sum(nil))
+	signature.addInt32(tagSigPayloadSize, int32(cpio.Len()))
+	signatureBytes := signature.bytes(tagHeaderSignatures)
+	// Header following the signature is aligned to 8 bytes
+	signatureBytes = append(signatureBytes, make([]byte, (8-len(signatureBytes)%8)%8)...)
+
+	for _, part := range [][]byte{rpmLead(p.Name + "-" + version + "-" + p.Release), signatureBytes, headerBytes, payload.Bytes()} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rpmLead returns legacy 96 bytes lead of binary package, only its magic is checked by rpm
+func rpmLead(name string) []byte {
+	lead := make([]byte, 96)
+	copy(lead, []byte{0xed, 0xab, 0xee, 0xdb, 3, 0})
+	binary.BigEndian.PutUint16(lead[6:], 0) // Binary package
+	binary.BigEndian.PutUint16(lead[8:], 1)
+	copy(lead[10:75], name)
+	binary.BigEndian.PutUint16(lead[76:], 1) // Linux
+	binary.BigEndian.PutUint16(lead[78:], 5) // Signature in header structure
+	return lead
+}
+
+// rpmEntry is a tag of RPM header with its encoded value
+type rpmEntry struct {
+	tag   int32
+	typ   int32
+	count int32
+	data  []byte
+}
+
+// rpmHeader is RPM header structure: index of tags followed by data store
+type rpmHeader struct {
+	entries []rpmEntry
+}
+
+func (h *rpmHeader) addStrings(tag int32, typ int32, values ...string) {
+	var data []byte
+	for _, value := range values {
+		data = append(append(data, value...), 0)
+	}
+	h.entries = append(h.entries, rpmEntry{tag, typ, int32(len(values)), data})
+}
+
+func (h *rpmHeader) addInt32(tag int32, values ...int32) {
+	data := make([]byte, 4*len(values))
+	for i, value := range values {
+		binary.BigEndian.PutUint32(data[4*i:], uint32(value))
+	}
+	h.entries = append(h.entries, rpmEntry{tag, rpmInt32, int32(len(values)), data})
+}
+
+func (h *rpmHeader) addInt16(tag int32, values ...int16) {
+	data := make([]byte, 2*len(values))
+	for i, value := range values {
+		binary.BigEndian.PutUint16(data[2*i:], uint16(value))
+	}
+	h.entries = append(h.entries, rpmEntry{tag, rpmInt16, int32(len(values)), data})
+}
+
+func (h *rpmHeader) addBin(tag int32, value []byte) {
+	h.entries = append(h.entries, rpmEntry{tag, rpmBin, int32(len(value)), value})
+}
+
+// bytes encodes header with all tags in region of regionTag, which rpm requires for signature and immutable header
+func (h *rpmHeader) bytes(regionTag int32) []byte {
+	sort.Slice(h.entries, func(i, j int) bool { return h.entries[i].tag < h.entries[j].tag })
+
+	var index, store bytes.Buffer
+	writeIndex := func(tag, typ, offset, count int32) {
+		binary.Write(&index, binary.BigEndian, []int32{tag, typ, offset, count})
+	}
+	for _, entry := range h.entries {
+		alignment := map[int32]int{rpmInt16: 2, rpmInt32: 4}[entry.typ]
+		for alignment > 0 && store.Len()%alignment != 0 {
+			store.WriteByte(0)
+		}
+		writeIndex(entry.tag, entry.typ, int32(store.Len()), entry.count)
+		store.Write(entry.data)
+	}
+
+	// Region trailer at the end of the store points back to the start of the index
+	entryCount := int32(len(h.entries) + 1)
+	var region bytes.Buffer
+	binary.Write(&region, binary.BigEndian, []int32{regionTag, rpmBin, int32(store.Len()), 16})
+	binary.Write(&store, binary.BigEndian, []int32{regionTag, rpmBin, -16 * entryCount, 16})
+
+	var out bytes.Buffer
+	out.Write([]byte{0x8e, 0xad, 0xe8, 0x01, 0, 0, 0, 0})
+	binary.Write(&out, binary.BigEndian, []int32{entryCount, int32(store.Len())})
+	out.Write(region.Bytes())
+	out.Write(index.Bytes())
+	out.Write(store.Bytes())
+	return out.Bytes()
+}
+
+// writeCpioEntry writes entry of cpio archive in SVR4 `newc` format used by rpm payloads
+func writeCpioEntry(w *bytes.Buffer, name string, inode uint32, mode uint32, mtime int64, contents []byte) {
+	nlink := uint32(1)
+	if name == "TRAILER!!!" {
+		nlink = 0
+	}
+	fmt.Fprintf(w, "070701%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x",
+		inode, mode, 0, 0, nlink, uint32(mtime), len(contents), 0, 0, 0, 0, len(name)+1, 0)
+	w.WriteString(name)
+	w.WriteByte(0)
+	// Name and data are padded to 4 bytes
+	for w.Len()%4 != 0 {
+		w.WriteByte(0)
+	}
+	w.Write(contents)
+	for w.Len()%4 != 0 {
+		w.WriteByte(0)
+	}
+}
@@ -0,0 +1,43 @@
+package processors
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readToolDirectives returns package paths declared with `tool` directives (Go 1.24+) in go.mod of moduleDir
+func readToolDirectives(moduleDir string) []string {
+	file, err := os.Open(filepath.Join(moduleDir, "go.mod"))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var tools []string
+	inBlock := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch {
+		case inBlock && fields[0] == ")":
+			inBlock = false
+		case inBlock:
+			tools = append(tools, strings.Trim(fields[0], `"`))
+		case fields[0] == "tool" && len(fields) > 1 && fields[1] == "(":
+			inBlock = true
+		case fields[0] == "tool" && len(fields) > 1:
+			tools = append(tools, strings.Trim(fields[1], `"`))
+		}
+	}
+	return tools
+}
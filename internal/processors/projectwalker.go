@@ -67,6 +67,7 @@ func findMainAndGoMod(startPath string, buildDir string, dest chan models.Projec
 					AppMainSrcDir: mainGoDir,
 					RootDir:       goModDir,
 					BuildDir:      buildDir,
+					Tools:         readToolDirectives(goModDir),
 				}
 				// Send the constructed project to the channel
 				dest <- project
@@ -0,0 +1,149 @@
+package remotecache
+
+import (
+	"autobuild-go/internal/colors"
+	"autobuild-go/internal/models"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Client stores and fetches files of cache entries on a remote server with HTTP GET and PUT of `<url>/<key>/<name>`.
+// Remote cache is an optimisation only: after the first network error the client logs a warning and stays disabled
+type Client struct {
+	url      string
+	token    string
+	username string
+	password string
+	readOnly bool
+	http     *http.Client
+
+	mutex    sync.Mutex
+	disabled bool
+}
+
+// New creates client of remote cache configured in conf, nil when no remote is configured
+func New(conf models.Cache, client *http.Client) *Client {
+	if conf.Remote == "" {
+		return nil
+	}
+	return &Client{
+		url:      strings.TrimSuffix(conf.Remote, "/"),
+		token:    os.ExpandEnv(conf.Token),
+		username: os.ExpandEnv(conf.Username),
+		password: os.ExpandEnv(conf.Password),
+		readOnly: conf.ReadOnly,
+		http:     client,
+	}
+}
+
+// Get downloads file name of entry key to dest. Returns false on cache miss or when remote is unavailable
+func (c *Client) Get(key string, name string, dest string) bool {
+	if c.isDisabled() {
+		return false
+	}
+
+	resp, err := c.do(http.MethodGet, key, name, nil, 0)
+	if err != nil {
+		c.disable(err)
+		return false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false
+	}
+	if resp.StatusCode != http.StatusOK {
+		c.disable(fmt.Errorf("GET %s/%s: %s", key, name, resp.Status))
+		return false
+	}
+
+	if err := writeFile(dest, resp.Body); err != nil {
+		colors.WarnLog("Cannot store file downloaded from remote cache: %v", err)
+		return false
+	}
+	return true
+}
+
+// Put uploads file src as name of entry key. Does nothing in read-only mode
+func (c *Client) Put(key string, name string, src string) {
+	if c.readOnly || c.isDisabled() {
+		return
+	}
+
+	file, err := os.Open(src)
+	if err != nil {
+		colors.WarnLog("Cannot upload `%s` to remote cache: %v", src, err)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		colors.WarnLog("Cannot upload `%s` to remote cache: %v", src, err)
+		return
+	}
+
+	resp, err := c.do(http.MethodPut, key, name, file, info.Size())
+	if err != nil {
+		c.disable(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		c.disable(fmt.Errorf("PUT %s/%s: %s", key, name, resp.Status))
+	}
+}
+
+// do sends authorized request for file name of entry key
+func (c *Client) do(method string, key string, name string, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.url+"/"+key+"/"+name, body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	} else if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return c.http.Do(req)
+}
+
+func (c *Client) isDisabled() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.disabled
+}
+
+// disable turns remote cache off for the rest of the run, so an unreachable server slows down only one request
+func (c *Client) disable(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.disabled {
+		c.disabled = true
+		colors.WarnLog("Remote cache %s unavailable, continuing without it: %v", c.url, err)
+	}
+}
+
+// writeFile writes contents of r to path through temporary file, so readers never see partial files
+func writeFile(path string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
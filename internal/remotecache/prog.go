@@ -0,0 +1,190 @@
+package remotecache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// progRequest is a request of GOCACHEPROG protocol (cmd/go/internal/cacheprog) sent by the go command
+type progRequest struct {
+	ID       int64
+	Command  string
+	ActionID []byte `json:",omitempty"`
+	OutputID []byte `json:",omitempty"`
+	BodySize int64  `json:",omitempty"`
+}
+
+// progResponse is a response of GOCACHEPROG protocol
+type progResponse struct {
+	ID            int64
+	Err           string     `json:",omitempty"`
+	KnownCommands []string   `json:",omitempty"`
+	Miss          bool       `json:",omitempty"`
+	OutputID      []byte     `json:",omitempty"`
+	Size          int64      `json:",omitempty"`
+	Time          *time.Time `json:",omitempty"`
+	DiskPath      string     `json:",omitempty"`
+}
+
+// progAction is a cache entry of an action, stored locally in `a/<action>` and remotely as `<action>/action`
+type progAction struct {
+	OutputID string    `json:"output_id"`
+	Size     int64     `json:"size"`
+	Time     time.Time `json:"time"`
+}
+
+// maxParallelUploads limits uploads of Go build cache entries running in background
+const maxParallelUploads = 4
+
+// Prog is a GOCACHEPROG program keeping Go build cache in a local directory and sharing it through remote cache
+type Prog struct {
+	dir     string
+	remote  *Client
+	uploads sync.WaitGroup
+	slots   chan struct{}
+}
+
+// NewProg creates GOCACHEPROG program storing objects in dir. remote may be nil
+func NewProg(dir string, remote *Client) *Prog {
+	return &Prog{dir: dir, remote: remote, slots: make(chan struct{}, maxParallelUploads)}
+}
+
+// Run serves requests of the go command read from in until `close` request or end of input
+func (p *Prog) Run(in io.Reader, out io.Writer) error {
+	for _, sub := range []string{"a", "o"} {
+		if err := os.MkdirAll(filepath.Join(p.dir, sub), os.ModePerm); err != nil {
+			return err
+		}
+	}
+
+	writer := bufio.NewWriter(out)
+	encoder := json.NewEncoder(writer)
+	respond := func(resp progResponse) error {
+		if err := encoder.Encode(resp); err != nil {
+			return err
+		}
+		return writer.Flush()
+	}
+	if err := respond(progResponse{KnownCommands: []string{"get", "put", "close"}}); err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bufio.NewReader(in))
+	for {
+		var req progRequest
+		if err := decoder.Decode(&req); err == io.EOF {
+			p.uploads.Wait()
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var resp progResponse
+		switch req.Command {
+		case "get":
+			resp = p.get(req)
+		case "put":
+			// Body follows the request as base64 encoded JSON string
+			var body []byte
+			if req.BodySize > 0 {
+				if err := decoder.Decode(&body); err != nil {
+					return fmt.Errorf("cannot read body of request %d: %v", req.ID, err)
+				}
+			}
+			resp = p.put(req, body)
+		case "close":
+			p.uploads.Wait()
+			return respond(progResponse{ID: req.ID})
+		default:
+			resp = progResponse{Err: "unknown command " + req.Command}
+		}
+		resp.ID = req.ID
+		if err := respond(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// get looks up action locally, then in remote cache
+func (p *Prog) get(req progRequest) progResponse {
+	actionID := hex.EncodeToString(req.ActionID)
+	actionPath := filepath.Join(p.dir, "a", actionID)
+
+	action, err := readAction(actionPath)
+	if err != nil && p.remote != nil && p.remote.Get(actionID, "action", actionPath) {
+		action, err = readAction(actionPath)
+	}
+	if err != nil {
+		return progResponse{Miss: true}
+	}
+
+	objectPath := filepath.Join(p.dir, "o", action.OutputID)
+	info, err := os.Stat(objectPath)
+	if err != nil && p.remote != nil && p.remote.Get(action.OutputID, "output", objectPath) {
+		info, err = os.Stat(objectPath)
+	}
+	if err != nil || info.Size() != action.Size {
+		return progResponse{Miss: true}
+	}
+
+	outputID, err := hex.DecodeString(action.OutputID)
+	if err != nil {
+		return progResponse{Miss: true}
+	}
+	return progResponse{OutputID: outputID, Size: action.Size, Time: &action.Time, DiskPath: objectPath}
+}
+
+// put stores object and action locally and uploads them to remote cache in background
+func (p *Prog) put(req progRequest, body []byte) progResponse {
+	if int64(len(body)) != req.BodySize {
+		return progResponse{Err: fmt.Sprintf("body size %d does not match declared %d", len(body), req.BodySize)}
+	}
+	actionID := hex.EncodeToString(req.ActionID)
+	outputID := hex.EncodeToString(req.OutputID)
+	actionPath := filepath.Join(p.dir, "a", actionID)
+	objectPath := filepath.Join(p.dir, "o", outputID)
+
+	if info, err := os.Stat(objectPath); err != nil || info.Size() != req.BodySize {
+		if err := writeFile(objectPath, bytes.NewReader(body)); err != nil {
+			return progResponse{Err: err.Error()}
+		}
+	}
+	action, err := json.Marshal(progAction{OutputID: outputID, Size: req.BodySize, Time: time.Now().UTC()})
+	if err != nil {
+		return progResponse{Err: err.Error()}
+	}
+	if err := writeFile(actionPath, bytes.NewReader(action)); err != nil {
+		return progResponse{Err: err.Error()}
+	}
+
+	if p.remote != nil {
+		p.uploads.Add(1)
+		go func() {
+			defer p.uploads.Done()
+			p.slots <- struct{}{}
+			defer func() { <-p.slots }()
+			// Object goes first, so other agents never see an action without its output
+			p.remote.Put(outputID, "output", objectPath)
+			p.remote.Put(actionID, "action", actionPath)
+		}()
+	}
+	return progResponse{DiskPath: objectPath}
+}
+
+func readAction(path string) (progAction, error) {
+	var action progAction
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return action, err
+	}
+	err = json.Unmarshal(contents, &action)
+	return action, err
+}
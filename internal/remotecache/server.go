@@ -0,0 +1,94 @@
+package remotecache
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	validKey  = regexp.MustCompile(`^[0-9a-f]{16,128}$`)
+	validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+)
+
+// Server serves cache files stored in a directory with GET, HEAD and PUT of `/<key>/<name>`
+type Server struct {
+	dir     string
+	token   string
+	maxSize int64
+}
+
+// NewServer creates server storing files in dir. When token is set, requests have to carry it
+// as bearer token or basic auth password. Uploads larger than maxSize bytes are rejected
+func NewServer(dir string, token string, maxSize int64) *Server {
+	return &Server{dir: dir, token: token, maxSize: maxSize}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="autobuild-go cache"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 2 || !validKey.MatchString(parts[0]) || !validName.MatchString(parts[1]) {
+		http.Error(w, "expected /<key>/<name>", http.StatusBadRequest)
+		return
+	}
+	path := filepath.Join(s.dir, parts[0][:2], parts[0], parts[1])
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		http.ServeContent(w, r, "", info.ModTime(), file)
+	case http.MethodPut:
+		if r.ContentLength > s.maxSize {
+			http.Error(w, "object too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err := writeFile(path, http.MaxBytesReader(w, r.Body, s.maxSize)); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "object too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authorized checks bearer token or basic auth password against configured token
+func (s *Server) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if _, password, ok := r.BasicAuth(); ok {
+		given = password
+	}
+	return subtle.ConstantTimeCompare([]byte(given), []byte(s.token)) == 1
+}
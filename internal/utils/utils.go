@@ -1,7 +1,10 @@
 package utils
 
 import (
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
 )
 
 // EnsureDir ensures that a directory exists, if not, creates it
@@ -11,3 +14,48 @@ func EnsureDir(path string) error {
 	}
 	return nil
 }
+
+// IsLocalURL tells if url points to a local file (plain path or file:// URL) rather than a network resource
+func IsLocalURL(url string) bool {
+	return !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://")
+}
+
+// LocalPath strips file:// scheme from local URL
+func LocalPath(url string) string {
+	return strings.TrimPrefix(url, "file://")
+}
+
+// HumanSize formats byte count using binary units
+func HumanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// CompareVersions compares dotted numeric versions (e.g. 1.22.5), returns -1, 0 or 1
+func CompareVersions(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var na, nb int
+		if i < len(pa) {
+			na, _ = strconv.Atoi(pa[i])
+		}
+		if i < len(pb) {
+			nb, _ = strconv.Atoi(pb[i])
+		}
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}